@@ -26,56 +26,124 @@ var (
 	psRequestFetchUrlFlag    = flag.String("psUrl", "", "Url to fetch path server requests from")
 	borderRouterFetchUrlFlag = flag.String("brUrl", "", "Url to fetch information about border router")
 
-	episodesFlag     = flag.Int("cEpisodes", defaultConfig.Episodes, "The amount of past episodes to save")
-	wDegreeFlag      = flag.Float64("cWDegree", defaultConfig.WeightDegree, "The weight for the degree")
-	wCapacityFlag    = flag.Float64("cWCapacity", defaultConfig.WeightCapacity, "The weight for the capacity")
-	wSuccessFlag     = flag.Float64("cWSuccess", defaultConfig.WeightSuccess, "The weight for the success")
-	wActivityFlag    = flag.Float64("cWActivity", defaultConfig.WeightActivity, "The weight for the activity")
-	speedCamDiffFlag = flag.Int("cSpeedCamDiff", defaultConfig.SpeedCamDiff, "Additional or fewer speed cams per episode")
-	verboseFlag      = flag.Bool("verbose", defaultConfig.Verbose, "Additional output")
-	resultDirFlag    = flag.String("resultDir", defaultConfig.ResultDir, "Write inspection results to that dir")
-
-	scaleTypeFlag  = flag.String("scaleType", defaultConfig.ScaleType, "How many SpeedCams should be selected? Supported: const, log and linear")
-	scaleParamFlag = flag.Float64("scaleParam", defaultConfig.ScaleParam, "The parameter for the scale func. Base for log, factor for linear and the const for const")
+	episodesFlag        = flag.Int("cEpisodes", defaultConfig.Episodes, "The amount of past episodes to save")
+	wDegreeFlag         = flag.Float64("cWDegree", defaultConfig.WeightDegree, "The weight for the degree")
+	wCapacityFlag       = flag.Float64("cWCapacity", defaultConfig.WeightCapacity, "The weight for the capacity")
+	wSuccessFlag        = flag.Float64("cWSuccess", defaultConfig.WeightSuccess, "The weight for the success")
+	wActivityFlag       = flag.Float64("cWActivity", defaultConfig.WeightActivity, "The weight for the activity")
+	wBetweennessFlag    = flag.Float64("cWBetweenness", defaultConfig.WeightBetweenness, "The weight for the betweenness centrality")
+	wPathCentralityFlag = flag.Float64("cWPathCentrality", defaultConfig.WeightPathCentrality, "The weight for the share of paths in path requests")
+	speedCamDiffFlag    = flag.Int("cSpeedCamDiff", defaultConfig.SpeedCamDiff, "Additional or fewer speed cams per episode")
+	verboseFlag         = flag.Bool("verbose", defaultConfig.Verbose, "Additional output")
+	resultDirFlag       = flag.String("resultDir", defaultConfig.ResultDir, "Write inspection results to that dir")
+
+	scaleTypeFlag         = flag.String("scaleType", defaultConfig.ScaleType, "How many SpeedCams should be selected? Supported: const, log and linear")
+	scaleParamFlag        = flag.Float64("scaleParam", defaultConfig.ScaleParam, "The parameter for the scale func. Base for log, factor for linear and the const for const")
+	selectionStratFlag    = flag.String("selectionStrategy", defaultConfig.SelectionStrategy, "How the SpeedCams are selected. Supported: probabilistic, topk, weighted, roundrobin and coverage")
+	selectionCoverageFlag = flag.Uint("selectionCoverage", defaultConfig.SelectionCoverage, "Episodes within every AS is selected at least once by the roundrobin strategy")
+	scoreNormFlag         = flag.String("scoreNormalization", defaultConfig.ScoreNormalization, "How the features of the candidate score are normalized before weighting. Supported: minmax, zscore, rank and none")
+	seedFlag              = flag.Int64("seed", defaultConfig.Seed, "Seed of the random selection and wait times. Zero for a seed chosen on start")
 
 	intervalStratFlag = flag.String("intervalStrat", defaultConfig.IntervalStrategy, "Strategy for waiting. Supported: fixed, random and experience")
 	intervalMinFlag   = flag.Uint("intervalMin", defaultConfig.IntervalWaitMin, "Seconds to wait at minimum till next inspection.")
 	intervalMaxFlag   = flag.Uint("intervalMax", defaultConfig.IntervalWaitMax, "Seconds to wait at maximum till next inspection.")
+
+	detectionStratFlag       = flag.String("detectionStrat", defaultConfig.DetectionStrategy, "Rule to detect a congestion. Supported: utilization, overflow, spike and any")
+	detectionUtilizationFlag = flag.Float64("detectionUtilization", defaultConfig.DetectionUtilization, "Share of the capacity a link must use to be detected as congested")
+	detectionOverflowFlag    = flag.Uint64("detectionOverflow", defaultConfig.DetectionOverflow, "Dropped input packets per second a link must exceed to be detected as congested")
+	detectionSpikeFactorFlag = flag.Float64("detectionSpikeFactor", defaultConfig.DetectionSpikeFactor, "Factor the bandwidth must exceed the average of previous episodes to be detected as congested")
+
+	capacityFileFlag = flag.String("capacityFile", defaultConfig.CapacityFile, "JSON file with link capacities")
+	topologyDirFlag  = flag.String("topologyDir", defaultConfig.TopologyDir, "Dir with SCION topology.json files to load link capacities from, e.g. SCION's gen dir")
+	bootstrapFlag    = flag.Bool("topologyBootstrap", defaultConfig.TopologyBootstrap, "Bootstrap the graph with the ASes and links of the topology dir")
+
+	measureStratFlag     = flag.String("measureStrat", defaultConfig.MeasurementStrategy, "How long a SpeedCam measures. Supported: fixed and adaptive")
+	measureMinFlag       = flag.Uint("measureMin", defaultConfig.MeasurementDuration, "Seconds a SpeedCam measures. Minimum for adaptive measurements.")
+	measureMaxFlag       = flag.Uint("measureMax", defaultConfig.MeasurementDurationMax, "Seconds a SpeedCam measures at maximum for adaptive measurements.")
+	measureVariationFlag = flag.Float64("measureVariation", defaultConfig.MeasurementVariation, "Coefficient of variation of the rates above which an adaptive measurement continues")
+	pollIntervalFlag     = flag.Uint("pollInterval", defaultConfig.PollInterval, "Seconds between two polls of a border router")
+	pollConcurrencyFlag  = flag.Int("pollConcurrency", defaultConfig.PollConcurrency, "Maximum of concurrent border router polls")
+	pollHostConcFlag     = flag.Int("pollHostConcurrency", defaultConfig.PollHostConcurrency, "Maximum of concurrent polls per border router host")
+	pollJitterFlag       = flag.Uint("pollJitter", defaultConfig.PollJitter, "Maximum random delay of the first poll in milliseconds")
+
+	graphTtlFlag = flag.Uint("graphTtl", defaultConfig.GraphTTL, "Minutes an AS or link stays in the graph without being seen. Zero for infinity")
+
+	stateFileFlag     = flag.String("stateFile", defaultConfig.StateFile, "Checkpoint the graph and episode history to this file and restore it on startup")
+	stateIntervalFlag = flag.Uint("stateInterval", defaultConfig.StateInterval, "Seconds between two checkpoints to the state file")
+
+	fetchPsIntervalFlag = flag.Uint("fetchPsInterval", defaultConfig.FetchPathRequestInterval, "Seconds between two fetches of the path requests")
+	fetchBrIntervalFlag = flag.Uint("fetchBrInterval", defaultConfig.FetchBrInfoInterval, "Seconds between two fetches of the border router information")
+	fetchBackoffFlag    = flag.Uint("fetchBackoff", defaultConfig.FetchBackoff, "Seconds to wait before retrying a failed fetch, doubled with every further failure")
+	fetchBackoffMaxFlag = flag.Uint("fetchBackoffMax", defaultConfig.FetchBackoffMax, "Maximum seconds to wait before retrying a failed fetch")
+
+	ingestAddrFlag = flag.String("ingestAddr", defaultConfig.IngestAddress, "Listen on this address for pushed path requests and border router information, e.g. ':8090'")
 )
 
 func main() {
 
 	flag.Parse()
 
-	if len(*psRequestFetchUrlFlag) == 0 {
+	if len(*psRequestFetchUrlFlag) == 0 && len(*ingestAddrFlag) == 0 {
 		flag.Usage()
-		sc.MyLogger.Criticalf("missing '-psUrl' parameter\n")
+		sc.MyLogger.Criticalf("missing '-psUrl' or '-ingestAddr' parameter\n")
 		return
 	}
-	if len(*borderRouterFetchUrlFlag) == 0 {
+	if len(*borderRouterFetchUrlFlag) == 0 && len(*ingestAddrFlag) == 0 {
 		flag.Usage()
-		sc.MyLogger.Criticalf("missing '-brUrl' parameter\n")
+		sc.MyLogger.Criticalf("missing '-brUrl' or '-ingestAddr' parameter\n")
 		return
 	}
 	config := getConfig()
 	sc.MyLogger.Debugf("Config: %v\n", config)
-	sc.RunProgram(config, *psRequestFetchUrlFlag, *borderRouterFetchUrlFlag)
+	// Stop on SIGINT or SIGTERM
+	ctx, cancel := sc.SignalContext()
+	defer cancel()
+	sc.RunProgram(ctx, config, *psRequestFetchUrlFlag, *borderRouterFetchUrlFlag)
 }
 
 func getConfig() *sc.SpeedCamConfig {
 	return &sc.SpeedCamConfig{
-		Episodes:         *episodesFlag,
-		WeightDegree:     *wDegreeFlag,
-		WeightCapacity:   *wCapacityFlag,
-		WeightSuccess:    *wSuccessFlag,
-		WeightActivity:   *wActivityFlag,
-		SpeedCamDiff:     *speedCamDiffFlag,
-		Verbose:          *verboseFlag,
-		ResultDir:        *resultDirFlag,
-		ScaleType:        *scaleTypeFlag,
-		ScaleParam:       *scaleParamFlag,
-		IntervalStrategy: *intervalStratFlag,
-		IntervalWaitMin:  *intervalMinFlag,
-		IntervalWaitMax:  *intervalMaxFlag,
+		Episodes:                 *episodesFlag,
+		WeightDegree:             *wDegreeFlag,
+		WeightCapacity:           *wCapacityFlag,
+		WeightSuccess:            *wSuccessFlag,
+		WeightActivity:           *wActivityFlag,
+		WeightBetweenness:        *wBetweennessFlag,
+		WeightPathCentrality:     *wPathCentralityFlag,
+		SpeedCamDiff:             *speedCamDiffFlag,
+		Verbose:                  *verboseFlag,
+		ResultDir:                *resultDirFlag,
+		ScaleType:                *scaleTypeFlag,
+		ScaleParam:               *scaleParamFlag,
+		SelectionStrategy:        *selectionStratFlag,
+		SelectionCoverage:        *selectionCoverageFlag,
+		ScoreNormalization:       *scoreNormFlag,
+		Seed:                     *seedFlag,
+		IntervalStrategy:         *intervalStratFlag,
+		IntervalWaitMin:          *intervalMinFlag,
+		IntervalWaitMax:          *intervalMaxFlag,
+		DetectionStrategy:        *detectionStratFlag,
+		DetectionUtilization:     *detectionUtilizationFlag,
+		DetectionOverflow:        *detectionOverflowFlag,
+		DetectionSpikeFactor:     *detectionSpikeFactorFlag,
+		CapacityFile:             *capacityFileFlag,
+		TopologyDir:              *topologyDirFlag,
+		TopologyBootstrap:        *bootstrapFlag,
+		MeasurementStrategy:      *measureStratFlag,
+		MeasurementDuration:      *measureMinFlag,
+		MeasurementDurationMax:   *measureMaxFlag,
+		MeasurementVariation:     *measureVariationFlag,
+		PollInterval:             *pollIntervalFlag,
+		PollConcurrency:          *pollConcurrencyFlag,
+		PollHostConcurrency:      *pollHostConcFlag,
+		PollJitter:               *pollJitterFlag,
+		GraphTTL:                 *graphTtlFlag,
+		StateFile:                *stateFileFlag,
+		StateInterval:            *stateIntervalFlag,
+		FetchPathRequestInterval: *fetchPsIntervalFlag,
+		FetchBrInfoInterval:      *fetchBrIntervalFlag,
+		FetchBackoff:             *fetchBackoffFlag,
+		FetchBackoffMax:          *fetchBackoffMaxFlag,
+		IngestAddress:            *ingestAddrFlag,
 	}
 }
@@ -0,0 +1,123 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/Meldanor/SCIONLab_SpeedCam/speed_cam"
+	"io/ioutil"
+	"os"
+)
+
+var (
+	resultFileFlag  = flag.String("result", "", "Inspection result file to export the graph of")
+	stateFileFlag   = flag.String("state", "", "State file of an inspector to export the graph of")
+	topologyDirFlag = flag.String("topologyDir", "", "Dir with SCION topology.json files to export the graph of, e.g. SCION's gen dir")
+	formatFlag      = flag.String("format", "dot", fmt.Sprintf("Export format. Supported: %v", speed_cam.ExportFormats))
+	outFlag         = flag.String("out", "", "File to write the export to. Standard output if empty")
+)
+
+func main() {
+
+	flag.Parse()
+
+	graph, err := loadGraph()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading graph. err: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = writeGraph(graph)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error exporting graph. err: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Writes the graph to the out file or the standard output. A failed close of the file fails the export, as the
+// export might not be written completely.
+func writeGraph(graph *speed_cam.ExportGraph) error {
+	if len(*outFlag) == 0 {
+		return graph.Write(os.Stdout, *formatFlag)
+	}
+	file, err := os.Create(*outFlag)
+	if err != nil {
+		return err
+	}
+	err = graph.Write(file, *formatFlag)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// Loads the graph of the only given source. The capacities of a state file are taken from the topology dir, a result
+// file cannot be combined with another source.
+func loadGraph() (*speed_cam.ExportGraph, error) {
+	if len(*resultFileFlag) != 0 && (len(*stateFileFlag) != 0 || len(*topologyDirFlag) != 0) {
+		fmt.Fprintln(os.Stderr, "-result cannot be combined with -state or -topologyDir")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	config := speed_cam.Default()
+	config.Verbose = false
+	config.TopologyDir = *topologyDirFlag
+
+	switch {
+	case len(*resultFileFlag) != 0:
+		readBytes, err := ioutil.ReadFile(*resultFileFlag)
+		if err != nil {
+			return nil, err
+		}
+		var result speed_cam.InspectionResult
+		err = json.Unmarshal(readBytes, &result)
+		if err != nil {
+			return nil, err
+		}
+		return result.ExportGraph(), nil
+	case len(*stateFileFlag) != 0:
+		// The inspector starts empty without a state file, which is no graph to export
+		if _, err := os.Stat(*stateFileFlag); err != nil {
+			return nil, err
+		}
+		config.StateFile = *stateFileFlag
+		inspector, err := speed_cam.CreateFromState(config)
+		if err != nil {
+			return nil, err
+		}
+		err = inspector.LoadCapacities()
+		if err != nil {
+			return nil, err
+		}
+		return inspector.Graph().Export(), nil
+	case len(*topologyDirFlag) != 0:
+		interfaces, err := speed_cam.LoadTopologyDir(*topologyDirFlag)
+		if err != nil {
+			return nil, err
+		}
+		graph := speed_cam.LoadTopology(interfaces, config)
+		graph.AddCapacities(speed_cam.TopologyCapacities(interfaces))
+		return graph.Export(), nil
+	default:
+		flag.Usage()
+		os.Exit(1)
+		return nil, nil
+	}
+}
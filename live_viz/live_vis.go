@@ -5,7 +5,6 @@ import (
 	"flag"
 	"fmt"
 	"github.com/Meldanor/SCIONLab_SpeedCam/speed_cam"
-	"github.com/c2h5oh/datasize"
 	"github.com/scionproto/scion/go/lib/addr"
 	"io/ioutil"
 	"net/http"
@@ -21,23 +20,59 @@ var (
 	psRequestFetchUrlFlag    = flag.String("psUrl", "", "Url to fetch path server requests from")
 	borderRouterFetchUrlFlag = flag.String("brUrl", "", "Url to fetch information about border router")
 
-	episodesFlag     = flag.Int("cEpisodes", defaultConfig.Episodes, "The amount of past episodes to save")
-	wDegreeFlag      = flag.Float64("cWDegree", defaultConfig.WeightDegree, "The weight for the degree")
-	wCapacityFlag    = flag.Float64("cWCapacity", defaultConfig.WeightCapacity, "The weight for the capacity")
-	wSuccessFlag     = flag.Float64("cWSuccess", defaultConfig.WeightSuccess, "The weight for the success")
-	wActivityFlag    = flag.Float64("cWActivity", defaultConfig.WeightActivity, "The weight for the activity")
-	speedCamDiffFlag = flag.Int("cSpeedCamDiff", defaultConfig.SpeedCamDiff, "Additional or fewer speed cams per episode")
-	verboseFlag      = flag.Bool("verbose", defaultConfig.Verbose, "Additional output")
-	resultDirFlag    = flag.String("resultDir", "./results/", "Write inspection results to that dir")
-	maxResultsFlag   = flag.Int("maxResults", 1, "Maximum amount of files before deleting old files. Zero or negative stands for infinity.")
-
-	scaleTypeFlag  = flag.String("scaleType", defaultConfig.ScaleType, "How many SpeedCams should be selected? Supported: const, log and linear")
-	scaleParamFlag = flag.Float64("scaleParam", defaultConfig.ScaleParam, "The parameter for the scale func. Base for log, factor for linear and the const for const")
+	episodesFlag        = flag.Int("cEpisodes", defaultConfig.Episodes, "The amount of past episodes to save")
+	wDegreeFlag         = flag.Float64("cWDegree", defaultConfig.WeightDegree, "The weight for the degree")
+	wCapacityFlag       = flag.Float64("cWCapacity", defaultConfig.WeightCapacity, "The weight for the capacity")
+	wSuccessFlag        = flag.Float64("cWSuccess", defaultConfig.WeightSuccess, "The weight for the success")
+	wActivityFlag       = flag.Float64("cWActivity", defaultConfig.WeightActivity, "The weight for the activity")
+	wBetweennessFlag    = flag.Float64("cWBetweenness", defaultConfig.WeightBetweenness, "The weight for the betweenness centrality")
+	wPathCentralityFlag = flag.Float64("cWPathCentrality", defaultConfig.WeightPathCentrality, "The weight for the share of paths in path requests")
+	speedCamDiffFlag    = flag.Int("cSpeedCamDiff", defaultConfig.SpeedCamDiff, "Additional or fewer speed cams per episode")
+	verboseFlag         = flag.Bool("verbose", defaultConfig.Verbose, "Additional output")
+	resultDirFlag       = flag.String("resultDir", "./results/", "Write inspection results to that dir")
+	maxResultsFlag      = flag.Int("maxResults", 1, "Maximum amount of files before deleting old files. Zero or negative stands for infinity.")
+
+	scaleTypeFlag         = flag.String("scaleType", defaultConfig.ScaleType, "How many SpeedCams should be selected? Supported: const, log and linear")
+	scaleParamFlag        = flag.Float64("scaleParam", defaultConfig.ScaleParam, "The parameter for the scale func. Base for log, factor for linear and the const for const")
+	selectionStratFlag    = flag.String("selectionStrategy", defaultConfig.SelectionStrategy, "How the SpeedCams are selected. Supported: probabilistic, topk, weighted, roundrobin and coverage")
+	selectionCoverageFlag = flag.Uint("selectionCoverage", defaultConfig.SelectionCoverage, "Episodes within every AS is selected at least once by the roundrobin strategy")
+	scoreNormFlag         = flag.String("scoreNormalization", defaultConfig.ScoreNormalization, "How the features of the candidate score are normalized before weighting. Supported: minmax, zscore, rank and none")
+	seedFlag              = flag.Int64("seed", defaultConfig.Seed, "Seed of the random selection and wait times. Zero for a seed chosen on start")
 
 	intervalStratFlag = flag.String("intervalStrat", defaultConfig.IntervalStrategy, "Strategy for waiting. Supported: fixed, random and experience")
 	intervalMinFlag   = flag.Uint("intervalMin", defaultConfig.IntervalWaitMin, "Seconds to wait at minimum till next inspection.")
 	intervalMaxFlag   = flag.Uint("intervalMax", defaultConfig.IntervalWaitMax, "Seconds to wait at maximum till next inspection.")
 
+	detectionStratFlag       = flag.String("detectionStrat", defaultConfig.DetectionStrategy, "Rule to detect a congestion. Supported: utilization, overflow, spike and any")
+	detectionUtilizationFlag = flag.Float64("detectionUtilization", defaultConfig.DetectionUtilization, "Share of the capacity a link must use to be detected as congested")
+	detectionOverflowFlag    = flag.Uint64("detectionOverflow", defaultConfig.DetectionOverflow, "Dropped input packets per second a link must exceed to be detected as congested")
+	detectionSpikeFactorFlag = flag.Float64("detectionSpikeFactor", defaultConfig.DetectionSpikeFactor, "Factor the bandwidth must exceed the average of previous episodes to be detected as congested")
+
+	capacityFileFlag = flag.String("capacityFile", defaultConfig.CapacityFile, "JSON file with link capacities")
+	topologyDirFlag  = flag.String("topologyDir", defaultConfig.TopologyDir, "Dir with SCION topology.json files to load link capacities from, e.g. SCION's gen dir")
+	bootstrapFlag    = flag.Bool("topologyBootstrap", defaultConfig.TopologyBootstrap, "Bootstrap the graph with the ASes and links of the topology dir")
+
+	measureStratFlag     = flag.String("measureStrat", defaultConfig.MeasurementStrategy, "How long a SpeedCam measures. Supported: fixed and adaptive")
+	measureMinFlag       = flag.Uint("measureMin", defaultConfig.MeasurementDuration, "Seconds a SpeedCam measures. Minimum for adaptive measurements.")
+	measureMaxFlag       = flag.Uint("measureMax", defaultConfig.MeasurementDurationMax, "Seconds a SpeedCam measures at maximum for adaptive measurements.")
+	measureVariationFlag = flag.Float64("measureVariation", defaultConfig.MeasurementVariation, "Coefficient of variation of the rates above which an adaptive measurement continues")
+	pollIntervalFlag     = flag.Uint("pollInterval", defaultConfig.PollInterval, "Seconds between two polls of a border router")
+	pollConcurrencyFlag  = flag.Int("pollConcurrency", defaultConfig.PollConcurrency, "Maximum of concurrent border router polls")
+	pollHostConcFlag     = flag.Int("pollHostConcurrency", defaultConfig.PollHostConcurrency, "Maximum of concurrent polls per border router host")
+	pollJitterFlag       = flag.Uint("pollJitter", defaultConfig.PollJitter, "Maximum random delay of the first poll in milliseconds")
+
+	graphTtlFlag = flag.Uint("graphTtl", defaultConfig.GraphTTL, "Minutes an AS or link stays in the graph without being seen. Zero for infinity")
+
+	stateFileFlag     = flag.String("stateFile", defaultConfig.StateFile, "Checkpoint the graph and episode history to this file and restore it on startup")
+	stateIntervalFlag = flag.Uint("stateInterval", defaultConfig.StateInterval, "Seconds between two checkpoints to the state file")
+
+	fetchPsIntervalFlag = flag.Uint("fetchPsInterval", defaultConfig.FetchPathRequestInterval, "Seconds between two fetches of the path requests")
+	fetchBrIntervalFlag = flag.Uint("fetchBrInterval", defaultConfig.FetchBrInfoInterval, "Seconds between two fetches of the border router information")
+	fetchBackoffFlag    = flag.Uint("fetchBackoff", defaultConfig.FetchBackoff, "Seconds to wait before retrying a failed fetch, doubled with every further failure")
+	fetchBackoffMaxFlag = flag.Uint("fetchBackoffMax", defaultConfig.FetchBackoffMax, "Maximum seconds to wait before retrying a failed fetch")
+
+	ingestAddrFlag = flag.String("ingestAddr", defaultConfig.IngestAddress, "Listen on this address for pushed path requests and border router information, e.g. ':8090'")
+
 	port = flag.Int("port", 6363, "The port to access the visualization @ http://localhost:PORT/index.html ")
 
 	loadedVisData []byte
@@ -47,14 +82,14 @@ func main() {
 
 	flag.Parse()
 
-	if len(*psRequestFetchUrlFlag) == 0 {
+	if len(*psRequestFetchUrlFlag) == 0 && len(*ingestAddrFlag) == 0 {
 		flag.Usage()
-		speed_cam.MyLogger.Criticalf("missing '-psUrl' parameter\n")
+		speed_cam.MyLogger.Criticalf("missing '-psUrl' or '-ingestAddr' parameter\n")
 		return
 	}
-	if len(*borderRouterFetchUrlFlag) == 0 {
+	if len(*borderRouterFetchUrlFlag) == 0 && len(*ingestAddrFlag) == 0 {
 		flag.Usage()
-		speed_cam.MyLogger.Criticalf("missing '-brUrl' parameter\n")
+		speed_cam.MyLogger.Criticalf("missing '-brUrl' or '-ingestAddr' parameter\n")
 		return
 	}
 	config := getConfig()
@@ -66,26 +101,58 @@ func main() {
 
 	go fileWatcher(config.ResultDir)
 
-	speed_cam.RunProgram(config, *psRequestFetchUrlFlag, *borderRouterFetchUrlFlag)
+	// Stop on SIGINT or SIGTERM
+	ctx, cancel := speed_cam.SignalContext()
+	defer cancel()
+	speed_cam.RunProgram(ctx, config, *psRequestFetchUrlFlag, *borderRouterFetchUrlFlag)
 
 }
 
 func getConfig() *speed_cam.SpeedCamConfig {
 	return &speed_cam.SpeedCamConfig{
-		Episodes:         *episodesFlag,
-		WeightDegree:     *wDegreeFlag,
-		WeightCapacity:   *wCapacityFlag,
-		WeightSuccess:    *wSuccessFlag,
-		WeightActivity:   *wActivityFlag,
-		SpeedCamDiff:     *speedCamDiffFlag,
-		Verbose:          *verboseFlag,
-		ResultDir:        *resultDirFlag,
-		MaxResults:       *maxResultsFlag,
-		ScaleType:        *scaleTypeFlag,
-		ScaleParam:       *scaleParamFlag,
-		IntervalStrategy: *intervalStratFlag,
-		IntervalWaitMin:  *intervalMinFlag,
-		IntervalWaitMax:  *intervalMaxFlag,
+		Episodes:                 *episodesFlag,
+		WeightDegree:             *wDegreeFlag,
+		WeightCapacity:           *wCapacityFlag,
+		WeightSuccess:            *wSuccessFlag,
+		WeightActivity:           *wActivityFlag,
+		WeightBetweenness:        *wBetweennessFlag,
+		WeightPathCentrality:     *wPathCentralityFlag,
+		SpeedCamDiff:             *speedCamDiffFlag,
+		Verbose:                  *verboseFlag,
+		ResultDir:                *resultDirFlag,
+		MaxResults:               *maxResultsFlag,
+		ScaleType:                *scaleTypeFlag,
+		ScaleParam:               *scaleParamFlag,
+		SelectionStrategy:        *selectionStratFlag,
+		SelectionCoverage:        *selectionCoverageFlag,
+		ScoreNormalization:       *scoreNormFlag,
+		Seed:                     *seedFlag,
+		IntervalStrategy:         *intervalStratFlag,
+		IntervalWaitMin:          *intervalMinFlag,
+		IntervalWaitMax:          *intervalMaxFlag,
+		DetectionStrategy:        *detectionStratFlag,
+		DetectionUtilization:     *detectionUtilizationFlag,
+		DetectionOverflow:        *detectionOverflowFlag,
+		DetectionSpikeFactor:     *detectionSpikeFactorFlag,
+		CapacityFile:             *capacityFileFlag,
+		TopologyDir:              *topologyDirFlag,
+		TopologyBootstrap:        *bootstrapFlag,
+		MeasurementStrategy:      *measureStratFlag,
+		MeasurementDuration:      *measureMinFlag,
+		MeasurementDurationMax:   *measureMaxFlag,
+		MeasurementVariation:     *measureVariationFlag,
+		PollInterval:             *pollIntervalFlag,
+		PollConcurrency:          *pollConcurrencyFlag,
+		PollHostConcurrency:      *pollHostConcFlag,
+		PollJitter:               *pollJitterFlag,
+		GraphTTL:                 *graphTtlFlag,
+		StateFile:                *stateFileFlag,
+		StateInterval:            *stateIntervalFlag,
+		FetchPathRequestInterval: *fetchPsIntervalFlag,
+		FetchBrInfoInterval:      *fetchBrIntervalFlag,
+		FetchBackoff:             *fetchBackoffFlag,
+		FetchBackoffMax:          *fetchBackoffMaxFlag,
+		IngestAddress:            *ingestAddrFlag,
 	}
 }
 
@@ -179,8 +246,7 @@ func createNodeData(result speed_cam.InspectionResult) []NodeData {
 	for k, v := range result.Graph {
 		nodeData := NodeData{Id: k.String(), Degree: v.Degree, CandidateScore: uint(v.CandidateScore)}
 		nodeIsdAs, _ := addr.IAFromString(nodeData.Id)
-		n := 0
-		bandwidth := datasize.B
+		rates := linkRates{}
 		for _, result := range result.SpeedCamResults {
 			for _, v := range result {
 				for _, r := range v {
@@ -188,17 +254,15 @@ func createNodeData(result speed_cam.InspectionResult) []NodeData {
 						nodeData.WasSpeedCam = true
 					}
 					if r.Source == nodeIsdAs || r.Neighbor == nodeIsdAs {
-						bandwidth += r.BandwidthIn + r.BandwidthOut
-						n++
+						rates.add(r)
 					}
 				}
 			}
 		}
-		if n == 0 {
-			nodeData.AvgBytes = 0
-		} else {
-			nodeData.AvgBytes = float64(bandwidth) / float64(n)
-		}
+		nodeData.AvgBytes = rates.average(rates.bytes)
+		nodeData.AvgPackets = rates.average(rates.packets)
+		nodeData.AvgDropped = rates.average(rates.dropped)
+		nodeData.AvgErrors = rates.average(rates.errors)
 
 		nodeDataSlice = append(nodeDataSlice, nodeData)
 	}
@@ -228,7 +292,20 @@ func createLinkData(result speed_cam.InspectionResult) []LinkData {
 	for k, v := range reducedGraph {
 		for _, n := range v {
 			linkData := LinkData{Source: k, Target: n}
-			linkData.AvgBytes = averageLinkBandwidth(k, n, result) + averageLinkBandwidth(n, k, result)
+			linkData.addHistory(result)
+			// The traffic of both directions adds up, so the averages of each direction are summed
+			forward, backward := linkRates{}, linkRates{}
+			forward.addLink(k, n, result)
+			backward.addLink(n, k, result)
+			linkData.AvgBytes = forward.average(forward.bytes) + backward.average(backward.bytes)
+			linkData.AvgPackets = forward.average(forward.packets) + backward.average(backward.packets)
+			linkData.AvgDropped = forward.average(forward.dropped) + backward.average(backward.dropped)
+			linkData.AvgErrors = forward.average(forward.errors) + backward.average(backward.errors)
+			packetSizes := forward.packetSizes
+			packetSizes.Add(backward.packetSizes)
+			linkData.AvgPacketSize = packetSizes.Mean()
+			linkData.PacketSizeLabels = packetSizes.Labels()
+			linkData.PacketSizeShares = packetSizes.Shares()
 			linksSlice = append(linksSlice, linkData)
 		}
 	}
@@ -236,39 +313,68 @@ func createLinkData(result speed_cam.InspectionResult) []LinkData {
 	return linksSlice
 }
 
-func averageLinkBandwidth(source string, target string, result speed_cam.InspectionResult) float64 {
-	n := 0
-	bandwidthTotal := datasize.B
-
-	sourceIsdAs, _ := addr.IAFromString(source)
-	targetIsdAs, _ := addr.IAFromString(target)
-	for _, v := range result.SpeedCamResults {
-
-		// check source->target way
-		results, exists := v[sourceIsdAs]
-		// Are the results from target to source -> count them
-		if exists && results[0].Neighbor == targetIsdAs {
-			for _, r := range results {
-				bandwidthTotal += r.BandwidthIn + r.BandwidthOut
-				n++
-			}
+// Adds the capacity and bandwidth history of all links between the ASes, parallel links are summed up
+func (linkData *LinkData) addHistory(result speed_cam.InspectionResult) {
+	for _, link := range result.Links {
+		source, target := link.Source.String(), link.Target.String()
+		if !(source == linkData.Source && target == linkData.Target) &&
+			!(source == linkData.Target && target == linkData.Source) {
+			continue
 		}
 
-		// Check target->source way
-		results, exists = v[targetIsdAs]
-		// Are the results from target to source -> count them
-		if exists && results[0].Source == sourceIsdAs {
-			for _, r := range results {
-				bandwidthTotal += r.BandwidthIn + r.BandwidthOut
-				n++
+		linkData.Capacity += float64(link.Capacity)
+		for i, v := range link.Activities {
+			bytes := float64(v.SourceToTarget + v.TargetToSource)
+			if i < len(linkData.History) {
+				linkData.History[i] += bytes
+			} else {
+				linkData.History = append(linkData.History, bytes)
 			}
 		}
 	}
+}
+
+// Sums up the rates of SpeedCam results to average them
+type linkRates struct {
+	n       int
+	bytes   float64
+	packets float64
+	dropped float64
+	errors  float64
+	// Packet sizes of both directions
+	packetSizes speed_cam.PacketSizeHistogram
+}
 
-	if n == 0 {
+func (rates *linkRates) add(r speed_cam.SpeedCamResult) {
+	rates.bytes += float64(r.BandwidthIn + r.BandwidthOut)
+	rates.packets += float64(r.PacketsIn + r.PacketsOut)
+	rates.dropped += float64(r.DroppedIn)
+	rates.errors += float64(r.Errors())
+	rates.packetSizes.Add(r.PacketSizesIn)
+	rates.packetSizes.Add(r.PacketSizesOut)
+	rates.n++
+}
+
+func (rates *linkRates) average(sum float64) float64 {
+	if rates.n == 0 {
 		return 0
 	}
-	return float64(bandwidthTotal) / float64(n)
+	return sum / float64(rates.n)
+}
+
+// Adds the results measured by a SpeedCam on source for its links to target
+func (rates *linkRates) addLink(source string, target string, result speed_cam.InspectionResult) {
+	sourceIsdAs, _ := addr.IAFromString(source)
+	targetIsdAs, _ := addr.IAFromString(target)
+	for _, v := range result.SpeedCamResults {
+		for _, results := range v {
+			if len(results) > 0 && results[0].Source == sourceIsdAs && results[0].Neighbor == targetIsdAs {
+				for _, r := range results {
+					rates.add(r)
+				}
+			}
+		}
+	}
 }
 
 type VisData struct {
@@ -283,11 +389,26 @@ type NodeData struct {
 	Degree         uint
 	CandidateScore uint
 	AvgBytes       float64
+	AvgPackets     float64
+	AvgDropped     float64
+	AvgErrors      float64
 	WasSpeedCam    bool
 }
 
 type LinkData struct {
-	Source   string `json:"source"`
-	Target   string `json:"target"`
-	AvgBytes float64
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	AvgBytes   float64
+	AvgPackets float64
+	AvgDropped float64
+	AvgErrors  float64
+	// Bytes per second, zero if unknown
+	Capacity float64
+	// Bytes per second of the previous episodes in both directions, latest first
+	History []float64
+	// Average bytes per packet
+	AvgPacketSize float64
+	// Share of the packets per packet size bucket
+	PacketSizeLabels []string
+	PacketSizeShares []float64
 }
@@ -0,0 +1,227 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/scionproto/scion/go/lib/addr"
+	"sort"
+)
+
+// Counts the path of a path request for the path centrality of its ASes.
+func (graph *NetworkGraph) AddPathRequest(isdAses []addr.IA) {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	// An AS appearing twice in a path still carries it once
+	onPath := make(map[addr.IA]bool)
+	for _, v := range isdAses {
+		if _, exists := graph.nodes[v]; exists {
+			onPath[v] = true
+		}
+	}
+	if len(onPath) == 0 {
+		return
+	}
+	for k := range onPath {
+		graph.pathCounts[k]++
+	}
+	graph.pathTotal++
+}
+
+// Share (0.0 - 1.0) of the counted path requests whose path contains the AS.
+func (graph *NetworkGraph) pathCentrality(isdAs addr.IA) float64 {
+	if graph.pathTotal == 0 {
+		return 0
+	}
+	return graph.pathCounts[isdAs] / graph.pathTotal
+}
+
+// The normalized betweenness centrality of the ASes of a snapshot taken at the structure version of the graph. It is
+// only updated after ASes were connected or removed, and then only the shortest paths from the sources affected by
+// the changed connections are calculated again. The nodes are a copy, so the calculation does not hold the lock of the
+// graph and writers are not blocked meanwhile.
+func (graph *NetworkGraph) betweenness(nodes map[addr.IA]networkNode, version uint64) map[addr.IA]float64 {
+	graph.centralityLock.Lock()
+	defer graph.centralityLock.Unlock()
+
+	cache := graph.betweennessCache
+	if cache != nil && cache.version == version {
+		return cache.centrality
+	}
+	state := updateBetweenness(cache, nodes)
+	state.version = version
+	// A snapshot taken before the cached one must not replace it
+	if cache == nil || version > cache.version {
+		graph.betweennessCache = state
+	}
+	return state.centrality
+}
+
+// The betweenness of a graph with the shortest paths of every source, so a changed graph only needs the sources
+// whose shortest paths changed.
+type betweennessState struct {
+	version uint64
+	// The sorted neighbors of every AS
+	neighbors map[addr.IA][]addr.IA
+	sources   map[addr.IA]*sourceDependencies
+	// Sources whose shortest paths were calculated for this state
+	calculated int
+	centrality map[addr.IA]float64
+}
+
+// The distances of the ASes reachable from a source and their dependencies on the shortest paths from it.
+type sourceDependencies struct {
+	distances    map[addr.IA]int
+	dependencies map[addr.IA]float64
+}
+
+// Calculates the betweenness centrality of the undirected graph with the algorithm of Brandes. Only the sources
+// affected by the connections changed since the previous state are calculated again, all of them without a previous
+// state. The values are normalized to 0.0 - 1.0 by the amount of pairs of other ASes. The ASes and their neighbors are
+// visited in sorted order, so the sums and thereby the values are the same for the same graph.
+func updateBetweenness(previous *betweennessState, nodes map[addr.IA]networkNode) *betweennessState {
+	state := &betweennessState{neighbors: make(map[addr.IA][]addr.IA, len(nodes)),
+		sources: make(map[addr.IA]*sourceDependencies, len(nodes)), centrality: make(map[addr.IA]float64, len(nodes))}
+	sources := make([]addr.IA, 0, len(nodes))
+	for k, v := range nodes {
+		sources = append(sources, k)
+		neighbors := make([]addr.IA, 0, len(v.neighbors))
+		for neighbor := range v.neighbors {
+			neighbors = append(neighbors, neighbor)
+		}
+		sortIsdAses(neighbors)
+		state.neighbors[k] = neighbors
+	}
+	sortIsdAses(sources)
+
+	affected := affectedSources(previous, state.neighbors)
+	for _, source := range sources {
+		dependencies, exists := previous.unaffected(source, affected)
+		if !exists {
+			dependencies = shortestPathDependencies(source, state.neighbors)
+			state.calculated++
+		}
+		state.sources[source] = dependencies
+		for k, v := range dependencies.dependencies {
+			if k != source {
+				state.centrality[k] += v
+			}
+		}
+	}
+
+	// Every pair was counted in both directions
+	n := float64(len(nodes))
+	pairs := (n - 1) * (n - 2)
+	for k := range nodes {
+		if pairs > 0 {
+			state.centrality[k] /= pairs
+		} else {
+			state.centrality[k] = 0
+		}
+	}
+	return state
+}
+
+// The dependencies of the source in the previous state, if its shortest paths did not change.
+func (state *betweennessState) unaffected(source addr.IA, affected map[addr.IA]bool) (*sourceDependencies, bool) {
+	if state == nil || affected[source] {
+		return nil, false
+	}
+	dependencies, exists := state.sources[source]
+	return dependencies, exists
+}
+
+// The sources of the previous state whose shortest paths use a removed connection or would use an added one. This
+// is the case if the two connected ASes have a different distance to the source, an unreachable AS has an infinite
+// one. Without a previous state, there is no source to keep.
+func affectedSources(previous *betweennessState, neighbors map[addr.IA][]addr.IA) map[addr.IA]bool {
+	affected := make(map[addr.IA]bool)
+	if previous == nil {
+		return affected
+	}
+	var changed [][2]addr.IA
+	changed = appendMissingConnections(changed, previous.neighbors, neighbors)
+	changed = appendMissingConnections(changed, neighbors, previous.neighbors)
+	for source, v := range previous.sources {
+		for _, connection := range changed {
+			distanceA, reachableA := v.distances[connection[0]]
+			distanceB, reachableB := v.distances[connection[1]]
+			if reachableA != reachableB || distanceA != distanceB {
+				affected[source] = true
+				break
+			}
+		}
+	}
+	return affected
+}
+
+// Appends the connections of the neighbors, which the other neighbors do not contain.
+func appendMissingConnections(connections [][2]addr.IA, neighbors map[addr.IA][]addr.IA,
+	other map[addr.IA][]addr.IA) [][2]addr.IA {
+
+	for k, v := range neighbors {
+		for _, neighbor := range v {
+			if isdAsLess(k, neighbor) && !containsIsdAs(other[k], neighbor) {
+				connections = append(connections, [2]addr.IA{k, neighbor})
+			}
+		}
+	}
+	return connections
+}
+
+// Checks if the sorted ISD-ASes contain the ISD-AS.
+func containsIsdAs(isdAses []addr.IA, isdAs addr.IA) bool {
+	i := sort.Search(len(isdAses), func(i int) bool {
+		return !isdAsLess(isdAses[i], isdAs)
+	})
+	return i < len(isdAses) && isdAses[i] == isdAs
+}
+
+// Calculates the shortest paths from the source and accumulates the dependencies of the ASes on them.
+func shortestPathDependencies(source addr.IA, neighbors map[addr.IA][]addr.IA) *sourceDependencies {
+	// Shortest paths from the source in BFS order
+	var stack []addr.IA
+	predecessors := make(map[addr.IA][]addr.IA)
+	paths := map[addr.IA]float64{source: 1}
+	distances := map[addr.IA]int{source: 0}
+
+	queue := []addr.IA{source}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		stack = append(stack, v)
+		for _, w := range neighbors[v] {
+			if _, visited := distances[w]; !visited {
+				distances[w] = distances[v] + 1
+				queue = append(queue, w)
+			}
+			if distances[w] == distances[v]+1 {
+				paths[w] += paths[v]
+				predecessors[w] = append(predecessors[w], v)
+			}
+		}
+	}
+
+	// Accumulate the dependencies in reverse BFS order
+	dependencies := make(map[addr.IA]float64)
+	for i := len(stack) - 1; i >= 0; i-- {
+		w := stack[i]
+		for _, v := range predecessors[w] {
+			dependencies[v] += paths[v] / paths[w] * (1 + dependencies[w])
+		}
+	}
+	return &sourceDependencies{distances: distances, dependencies: dependencies}
+}
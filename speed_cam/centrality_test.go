@@ -0,0 +1,163 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"fmt"
+	"github.com/scionproto/scion/go/lib/addr"
+	"math"
+	"testing"
+)
+
+// Test topology: 1-10 <-> 1-11 <-> 1-12 <-> 1-13, later 1-13 <-> 1-10 closes the circle
+func TestBetweenness(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	as112, _ := addr.IAFromString("1-12")
+	as113, _ := addr.IAFromString("1-13")
+	graph := Load(map[addr.IA][]addr.IA{as110: {as111}, as111: {as112}, as112: {as113}, as113: {}}, Default())
+
+	expected := map[addr.IA]float64{as110: 0, as111: 2.0 / 3.0, as112: 2.0 / 3.0, as113: 0}
+	snapshot := graph.Snapshot()
+	for k, v := range expected {
+		if actual := snapshot.nodes[k].info.betweenness; math.Abs(actual-v) > 1e-9 {
+			t.Errorf("Expected betweenness %v of %v in the line, but was %v", v, k, actual)
+		}
+	}
+
+	// Every AS is between its two opposite ASes on one of two shortest paths
+	graph.ConnectIsdAses(as113, as110)
+	snapshot = graph.Snapshot()
+	for k := range expected {
+		if actual := snapshot.nodes[k].info.betweenness; math.Abs(actual-1.0/6.0) > 1e-9 {
+			t.Errorf("Expected betweenness 1/6 of %v in the circle, but was %v", k, actual)
+		}
+	}
+}
+
+func TestPathCentrality(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	as112, _ := addr.IAFromString("1-12")
+	as120, _ := addr.IAFromString("1-20")
+	graph := Load(map[addr.IA][]addr.IA{as110: {as111}, as111: {as112}, as112: {}}, Default())
+
+	graph.AddPathRequest([]addr.IA{as110, as111, as112})
+	graph.AddPathRequest([]addr.IA{as111, as112, as111})
+	graph.AddPathRequest([]addr.IA{as110, as111})
+	// Unknown ASes are not counted
+	graph.AddPathRequest([]addr.IA{as120})
+
+	expected := map[addr.IA]float64{as110: 2.0 / 3.0, as111: 1, as112: 2.0 / 3.0}
+	snapshot := graph.Snapshot()
+	for k, v := range expected {
+		if actual := snapshot.nodes[k].info.pathCentrality; math.Abs(actual-v) > 1e-9 {
+			t.Errorf("Expected path centrality %v of %v, but was %v", v, k, actual)
+		}
+	}
+
+	restored, err := RestoreGraph(graph.State(), Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := restored.Snapshot().nodes[as110].info.pathCentrality; math.Abs(actual-2.0/3.0) > 1e-9 {
+		t.Errorf("Expected the restored path centrality 2/3 of 1-10, but was %v", actual)
+	}
+}
+
+// The same graph must result in exactly the same values, independent of the map order
+func TestBetweennessReproducible(t *testing.T) {
+	connections := make(map[addr.IA][]addr.IA)
+	for i := 1; i <= 30; i++ {
+		isdAs, _ := addr.IAFromString(fmt.Sprintf("1-%v", i))
+		connections[isdAs] = nil
+		for _, j := range []int{i * 2, i*3 + 1, i + 7} {
+			if j <= 30 {
+				neighbor, _ := addr.IAFromString(fmt.Sprintf("1-%v", j))
+				connections[isdAs] = append(connections[isdAs], neighbor)
+			}
+		}
+	}
+	graph := Load(connections, Default())
+
+	expected := updateBetweenness(nil, graph.nodes).centrality
+	for i := 0; i < 20; i++ {
+		actual := updateBetweenness(nil, graph.nodes).centrality
+		for k, v := range expected {
+			if actual[k] != v {
+				t.Fatalf("Expected betweenness %v of %v, but was %v", v, k, actual[k])
+			}
+		}
+	}
+}
+
+// Test topology: the lines 1-10 <-> 1-11 <-> 1-12 <-> 1-13 and 2-10 <-> 2-11 <-> 2-12 <-> 2-13. Changes of one line
+// do not affect the shortest paths from the other one.
+func TestBetweennessIncremental(t *testing.T) {
+	connections := make(map[addr.IA][]addr.IA)
+	for _, isd := range []int{1, 2} {
+		for i := 10; i <= 13; i++ {
+			isdAs, _ := addr.IAFromString(fmt.Sprintf("%v-%v", isd, i))
+			neighbor, _ := addr.IAFromString(fmt.Sprintf("%v-%v", isd, i+1))
+			if i < 13 {
+				connections[isdAs] = []addr.IA{neighbor}
+			} else {
+				connections[isdAs] = nil
+			}
+		}
+	}
+	graph := Load(connections, Default())
+	graph.Snapshot()
+	if calculated := graph.betweennessCache.calculated; calculated != 8 {
+		t.Errorf("Expected all 8 sources calculated, but was %v", calculated)
+	}
+
+	as110, _ := addr.IAFromString("1-10")
+	as113, _ := addr.IAFromString("1-13")
+	as210, _ := addr.IAFromString("2-10")
+	as31, _ := addr.IAFromString("3-1")
+	changes := []struct {
+		change     func()
+		calculated int
+	}{
+		// Closing the circle changes the shortest paths from every AS of the line
+		{func() { graph.ConnectIsdAses(as113, as110) }, 4},
+		// A new AS and the sources of the line it is connected to
+		{func() {
+			graph.AddIsdAs(as31)
+			graph.ConnectIsdAses(as31, as210)
+		}, 5},
+		// The removed AS was reachable from every AS of its line
+		{func() {
+			graph.lock.Lock()
+			graph.removeIsdAs(as31)
+			graph.lock.Unlock()
+		}, 4},
+	}
+	for _, v := range changes {
+		v.change()
+		snapshot := graph.Snapshot()
+		if calculated := graph.betweennessCache.calculated; calculated != v.calculated {
+			t.Errorf("Expected %v sources calculated, but was %v", v.calculated, calculated)
+		}
+		expected := updateBetweenness(nil, snapshot.nodes).centrality
+		for k, node := range snapshot.nodes {
+			if node.info.betweenness != expected[k] {
+				t.Errorf("Expected betweenness %v of %v, but was %v", expected[k], k, node.info.betweenness)
+			}
+		}
+	}
+}
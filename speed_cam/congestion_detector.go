@@ -0,0 +1,130 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+// Decides whether the results of a SpeedCam show a congestion or a violation. A detection counts as a success for the
+// SpeedCam's AS and increases its chance to be selected again.
+type CongestionDetector interface {
+	// The results are grouped by the measured links of the SpeedCam.
+	Detect(info *speedCamInfo, results map[LinkKey]SpeedCamResults) bool
+}
+
+// Provides the capacity of a measured link, e.g. the NetworkGraph.
+type LinkCapacities interface {
+	InterfaceCapacity(source addr.IA, ifId int, target addr.IA) datasize.ByteSize
+}
+
+// Creates the detector configured by the detection strategy. The capacities are used to calculate the utilization of
+// the measured links.
+func CreateDetector(config *SpeedCamConfig, capacities LinkCapacities) CongestionDetector {
+	switch config.DetectionStrategy {
+	case "utilization":
+		return &utilizationDetector{threshold: config.DetectionUtilization, capacities: capacities}
+	case "overflow":
+		return &overflowDetector{threshold: config.DetectionOverflow}
+	case "spike":
+		return &spikeDetector{factor: config.DetectionSpikeFactor}
+	case "any":
+		return &anyDetector{detectors: []CongestionDetector{
+			&utilizationDetector{threshold: config.DetectionUtilization, capacities: capacities},
+			&overflowDetector{threshold: config.DetectionOverflow},
+			&spikeDetector{factor: config.DetectionSpikeFactor},
+		}}
+	default:
+		MyLogger.Panicf("Unsupported detection strategy '%v'", config.DetectionStrategy)
+		return nil
+	}
+}
+
+// Detects a link whose bandwidth exceeds a share of its capacity. Links without a known capacity are not detected.
+type utilizationDetector struct {
+	threshold  float64
+	capacities LinkCapacities
+}
+
+func (detector *utilizationDetector) Detect(info *speedCamInfo, results map[LinkKey]SpeedCamResults) bool {
+	for _, v := range results {
+		for _, result := range v {
+			capacity := detector.capacities.InterfaceCapacity(result.Source, result.IfId, result.Neighbor)
+			if capacity == 0 {
+				continue
+			}
+			utilization := (result.BandwidthIn + result.BandwidthOut) / float64(capacity)
+			if utilization >= detector.threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Detects a link on which more input packets per second were dropped because of buffer overflows than allowed.
+type overflowDetector struct {
+	threshold uint64
+}
+
+func (detector *overflowDetector) Detect(info *speedCamInfo, results map[LinkKey]SpeedCamResults) bool {
+	for _, v := range results {
+		for _, result := range v {
+			if result.DroppedIn > float64(detector.threshold) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Detects a bandwidth exceeding the average activity of the previous episodes by a factor. Without history nothing
+// is detected.
+type spikeDetector struct {
+	factor float64
+}
+
+func (detector *spikeDetector) Detect(info *speedCamInfo, results map[LinkKey]SpeedCamResults) bool {
+	history, exists := info.AverageBandwidth()
+	if !exists || history == 0 {
+		return false
+	}
+
+	// Same aggregation as the activity of the SpeedCam: the average outgoing bandwidth summed over all links
+	bandwidth := 0.0
+	for _, v := range results {
+		for _, result := range v {
+			bandwidth += result.BandwidthOut / float64(len(v))
+		}
+	}
+
+	return bandwidth >= float64(history)*detector.factor
+}
+
+// Detects a congestion if any of its detectors does.
+type anyDetector struct {
+	detectors []CongestionDetector
+}
+
+func (detector *anyDetector) Detect(info *speedCamInfo, results map[LinkKey]SpeedCamResults) bool {
+	for _, v := range detector.detectors {
+		if v.Detect(info, results) {
+			return true
+		}
+	}
+	return false
+}
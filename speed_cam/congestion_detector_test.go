@@ -0,0 +1,113 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+	"testing"
+	"time"
+)
+
+func detectorResults(bandwidth datasize.ByteSize, dropped float64) map[LinkKey]SpeedCamResults {
+	as17, _ := addr.IAFromString("1-7")
+	as18, _ := addr.IAFromString("1-8")
+	return map[LinkKey]SpeedCamResults{
+		NewLinkKey(as17, 1, as18, 0): {{Source: as17, Neighbor: as18, IfId: 1, BandwidthOut: float64(bandwidth),
+			DroppedIn: dropped}},
+	}
+}
+
+func TestUtilizationDetector(t *testing.T) {
+	as17, _ := addr.IAFromString("1-7")
+	as18, _ := addr.IAFromString("1-8")
+	config := Default()
+	config.DetectionStrategy = "utilization"
+	graph := CreateEmpty(config)
+	detector := CreateDetector(config, graph)
+	info := NewInfo(as17, config)
+
+	if detector.Detect(info, detectorResults(9*datasize.GB, 0)) {
+		t.Error("Expected no detection without capacity")
+	}
+
+	// Two parallel links, only the measured one counts
+	graph.AddCapacities([]LinkCapacity{
+		{Source: as17, Target: as18, IfId: 1, Capacity: 10 * datasize.GB},
+		{Source: as17, Target: as18, IfId: 2, Capacity: 10 * datasize.GB},
+	})
+	if detector.Detect(info, detectorResults(7*datasize.GB, 0)) {
+		t.Error("Expected no detection for 70% utilization")
+	}
+	if !detector.Detect(info, detectorResults(9*datasize.GB, 0)) {
+		t.Error("Expected detection for 90% utilization")
+	}
+}
+
+func TestOverflowDetector(t *testing.T) {
+	as17, _ := addr.IAFromString("1-7")
+	config := Default()
+	config.DetectionStrategy = "overflow"
+	detector := CreateDetector(config, CreateEmpty(config))
+	info := NewInfo(as17, config)
+
+	if detector.Detect(info, detectorResults(0, 0)) {
+		t.Error("Expected no detection without dropped packets")
+	}
+	if detector.Detect(info, detectorResults(0, 5)) {
+		t.Error("Expected no detection with dropped packets below the threshold")
+	}
+	if !detector.Detect(info, detectorResults(0, 50)) {
+		t.Error("Expected detection with dropped packets above the threshold")
+	}
+}
+
+func TestSpikeDetector(t *testing.T) {
+	as17, _ := addr.IAFromString("1-7")
+	config := Default()
+	config.DetectionStrategy = "spike"
+	detector := CreateDetector(config, CreateEmpty(config))
+	info := NewInfo(as17, config)
+
+	if detector.Detect(info, detectorResults(5*datasize.MB, 0)) {
+		t.Error("Expected no detection without history")
+	}
+
+	date := time.Date(2018, 02, 23, 10, 0, 0, 0, time.Local)
+	info.AddActivity(date, 30*time.Second, 1*datasize.MB)
+	info.AddActivity(date, 30*time.Second, 3*datasize.MB)
+
+	if detector.Detect(info, detectorResults(3*datasize.MB, 0)) {
+		t.Error("Expected no detection for 1.5 times the history")
+	}
+	if !detector.Detect(info, detectorResults(5*datasize.MB, 0)) {
+		t.Error("Expected detection for 2.5 times the history")
+	}
+}
+
+func TestAnyDetector(t *testing.T) {
+	as17, _ := addr.IAFromString("1-7")
+	config := Default()
+	detector := CreateDetector(config, CreateEmpty(config))
+	info := NewInfo(as17, config)
+
+	if detector.Detect(info, detectorResults(5*datasize.MB, 0)) {
+		t.Error("Expected no detection")
+	}
+	if !detector.Detect(info, detectorResults(5*datasize.MB, 50)) {
+		t.Error("Expected detection by the overflow rule")
+	}
+}
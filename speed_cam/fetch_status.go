@@ -0,0 +1,184 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The sources the inspector fetches data from and the sources pushing data to the ingest endpoint.
+const (
+	FetchSourcePathRequests = "pathRequests"
+	FetchSourceBrInfo       = "brInfo"
+	PushSourcePathRequests  = "pathRequestsPush"
+	PushSourceBrInfo        = "brInfoPush"
+)
+
+// The health of a data source of the inspector.
+type FetchStatus struct {
+	Source      string
+	LastSuccess time.Time
+	LastError   time.Time
+	// The error of the last failed fetch. Empty if the last fetch succeeded
+	Error string
+	// Failed fetches since the last success
+	Failures int
+	// When the source is fetched again
+	NextFetch time.Time
+}
+
+// Checks if the data of the source is older than the period. A source never fetched successfully is always stale.
+func (status FetchStatus) Stale(now time.Time, period time.Duration) bool {
+	return status.LastSuccess.IsZero() || now.Sub(status.LastSuccess) > period
+}
+
+func (status FetchStatus) String() string {
+	if status.Failures == 0 {
+		return fmt.Sprintf("%v: last success %v", status.Source, status.LastSuccess)
+	}
+	return fmt.Sprintf("%v: %v failures since %v, last error '%v' at %v, next fetch %v", status.Source,
+		status.Failures, status.LastSuccess, status.Error, status.LastError, status.NextFetch)
+}
+
+// The status of all sources, safe for concurrent use.
+type fetchStatuses struct {
+	lock     sync.RWMutex
+	statuses map[string]*FetchStatus
+}
+
+func (statuses *fetchStatuses) update(source string, err error, at time.Time, next time.Time) {
+	statuses.lock.Lock()
+	defer statuses.lock.Unlock()
+
+	if statuses.statuses == nil {
+		statuses.statuses = make(map[string]*FetchStatus)
+	}
+	status, exists := statuses.statuses[source]
+	if !exists {
+		status = &FetchStatus{Source: source}
+		statuses.statuses[source] = status
+	}
+	if err != nil {
+		status.LastError = at
+		status.Error = err.Error()
+		status.Failures++
+	} else {
+		status.LastSuccess = at
+		status.Error = ""
+		status.Failures = 0
+	}
+	status.NextFetch = next
+}
+
+// The status of all fetched sources sorted by source.
+func (statuses *fetchStatuses) all() []FetchStatus {
+	statuses.lock.RLock()
+	defer statuses.lock.RUnlock()
+
+	result := make([]FetchStatus, 0, len(statuses.statuses))
+	for _, v := range statuses.statuses {
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Source < result[j].Source
+	})
+	return result
+}
+
+// The shortest delay before retrying a failed fetch, so a failing source is not retried in a busy loop.
+const minFetchBackoff = time.Second
+
+// The delay before the next try after the given amount of failures in a row. It doubles with every failure up to
+// the maximum and is randomized between the half and the full delay, so failed fetches are not retried in lockstep.
+// It is never shorter than minFetchBackoff.
+func backoffDelay(failures int, initial time.Duration, max time.Duration, random *rand.Rand) time.Duration {
+	if initial < minFetchBackoff {
+		initial = minFetchBackoff
+	}
+	delay := initial
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	if delay <= minFetchBackoff {
+		return minFetchBackoff
+	}
+	delay = delay/2 + time.Duration(random.Int63n(int64(delay/2)))
+	if delay < minFetchBackoff {
+		return minFetchBackoff
+	}
+	return delay
+}
+
+// Calls fetch every period until the context is cancelled. Failed fetches are retried with an exponential backoff
+// instead of the period.
+func (inspector *Inspector) fetchPeriodically(ctx context.Context, source string, period time.Duration,
+	fetch func(ctx context.Context) error) error {
+
+	initial := time.Duration(inspector.config.FetchBackoff) * time.Second
+	max := time.Duration(inspector.config.FetchBackoffMax) * time.Second
+	failures := 0
+	for {
+		err := fetch(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		now := time.Now()
+		wait := period
+		if err == nil {
+			failures = 0
+		} else {
+			failures++
+			wait = backoffDelay(failures, initial, max, inspector.fetchRandom)
+			MyLogger.Errorf("error fetching %v (%v failures in a row), retry in %v, err: %v\n", source, failures,
+				wait, err)
+		}
+		inspector.fetchStatuses.update(source, err, now, now.Add(wait))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Logs the sources which failed since the last inspection.
+func (inspector *Inspector) warnStaleSources(now time.Time) {
+	periods := map[string]time.Duration{
+		FetchSourcePathRequests: time.Duration(inspector.config.FetchPathRequestInterval) * time.Second,
+		FetchSourceBrInfo:       time.Duration(inspector.config.FetchBrInfoInterval) * time.Second,
+	}
+	for _, status := range inspector.FetchStatus() {
+		if status.Failures > 0 && status.Stale(now, periods[status.Source]) {
+			MyLogger.Warningf("Data of %v is going stale, %v", status.Source, status)
+		}
+	}
+}
+
+// The status of the path request and BR information sources. Operators can see if the data of the inspector is
+// going stale.
+func (inspector *Inspector) FetchStatus() []FetchStatus {
+	return inspector.fetchStatuses.all()
+}
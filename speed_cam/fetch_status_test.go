@@ -0,0 +1,101 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second,
+		10 * time.Second}
+	for i, v := range expected {
+		delay := backoffDelay(i+1, time.Second, 10*time.Second, newRandom(1, randomStreamFetch))
+		if delay < v/2 || delay > v {
+			t.Errorf("Expected a delay of %v - %v after %v failures, but was %v", v/2, v, i+1, delay)
+		}
+	}
+	if delay := backoffDelay(3, 0, 0, newRandom(1, randomStreamFetch)); delay != minFetchBackoff {
+		t.Errorf("Expected the minimum delay without backoff, but was %v", delay)
+	}
+}
+
+func TestValidateBackoff(t *testing.T) {
+	config := Default()
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid default config, but was %v", err)
+	}
+	config.FetchBackoffMax = config.FetchBackoff - 1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error for a maximum backoff smaller than the backoff")
+	}
+}
+
+func TestValidateIntervals(t *testing.T) {
+	for _, setZero := range []func(config *SpeedCamConfig){
+		func(config *SpeedCamConfig) { config.PollInterval = 0 },
+		func(config *SpeedCamConfig) { config.StateInterval = 0 },
+		func(config *SpeedCamConfig) { config.FetchPathRequestInterval = 0 },
+		func(config *SpeedCamConfig) { config.FetchBrInfoInterval = 0 },
+	} {
+		config := Default()
+		setZero(config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected an error for a zero interval in %v", config)
+		}
+	}
+}
+
+// The loop keeps going after failures and records them
+func TestFetchPeriodically(t *testing.T) {
+	config := Default()
+	config.FetchBackoff = 0
+	inspector := CreateEmptyGraph(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	var statuses [][]FetchStatus
+	fetch := func(ctx context.Context) error {
+		statuses = append(statuses, inspector.FetchStatus())
+		calls++
+		switch calls {
+		case 1, 2:
+			return errors.New("network blip")
+		case 3:
+			return nil
+		default:
+			cancel()
+			return nil
+		}
+	}
+	err := inspector.fetchPeriodically(ctx, FetchSourcePathRequests, 0, fetch)
+
+	if err != context.Canceled || calls != 4 {
+		t.Fatalf("Expected 4 calls until the cancel, but was %v (err: %v)", calls, err)
+	}
+	if failed := statuses[2][0]; failed.Failures != 2 || failed.Error != "network blip" ||
+		!failed.LastSuccess.IsZero() || !failed.Stale(time.Now(), time.Hour) {
+		t.Errorf("Expected 2 failures, but was %v", failed)
+	}
+	recovered := inspector.FetchStatus()[0]
+	if recovered.Failures != 0 || recovered.Error != "" || recovered.LastError.IsZero() ||
+		recovered.Stale(time.Now(), time.Hour) {
+		t.Errorf("Expected a recovered source, but was %v", recovered)
+	}
+}
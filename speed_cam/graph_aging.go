@@ -0,0 +1,168 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"fmt"
+	"github.com/scionproto/scion/go/lib/addr"
+	"time"
+)
+
+// An AS or a link removed from the graph, because it was not seen for longer than the TTL.
+type RemovalEvent struct {
+	// The removed AS, zero for a removed link
+	IsdAs addr.IA
+	// The removed link, zero for a removed AS
+	Link     LinkKey
+	LastSeen time.Time
+}
+
+// Checks if a link was removed instead of an AS.
+func (event RemovalEvent) IsLink() bool {
+	return event.Link != LinkKey{}
+}
+
+func (event RemovalEvent) String() string {
+	if event.IsLink() {
+		return fmt.Sprintf("link %v (last seen %v)", event.Link, event.LastSeen)
+	}
+	return fmt.Sprintf("AS %v (last seen %v)", event.IsdAs, event.LastSeen)
+}
+
+// Registers a listener called for every AS and link removed by Expire. It is called without holding the lock of
+// the graph.
+func (graph *NetworkGraph) OnRemoval(listener func(event RemovalEvent)) {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	graph.removalListeners = append(graph.removalListeners, listener)
+}
+
+// Marks the AS as seen at the given time, e.g. in a path request. Unknown ASes are ignored.
+func (graph *NetworkGraph) MarkSeen(isdAs addr.IA, at time.Time) {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	graph.markSeen(isdAs, at)
+}
+
+// Marks the links between the interfaces of both ASes and the ASes themselves as seen at the given time. An
+// interface id of zero matches every interface. Unknown links are ignored.
+func (graph *NetworkGraph) MarkLinkSeen(source addr.IA, sourceIfId int, target addr.IA, targetIfId int,
+	at time.Time) {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	key := NewLinkKey(source, sourceIfId, target, targetIfId)
+	for k, link := range graph.links {
+		if k.matches(key) && at.After(link.lastSeen) {
+			link.lastSeen = at
+		}
+	}
+	graph.markSeen(source, at)
+	graph.markSeen(target, at)
+}
+
+func (graph *NetworkGraph) markSeen(isdAs addr.IA, at time.Time) {
+	if seen, exists := graph.seen[isdAs]; exists && at.After(seen) {
+		graph.seen[isdAs] = at
+	}
+}
+
+// Removes all ASes and links not seen for longer than the TTL of the config. The degrees of the neighbors of a
+// removed AS decrease and the removal listeners are called for every removed AS and link. A TTL of zero keeps
+// everything forever, so do the ASes and links of the topology files.
+func (graph *NetworkGraph) Expire(now time.Time) []RemovalEvent {
+	ttl := time.Duration(graph.config.GraphTTL) * time.Minute
+	if ttl <= 0 {
+		return nil
+	}
+
+	graph.lock.Lock()
+	var events []RemovalEvent
+	for k, link := range graph.links {
+		if !link.static && now.Sub(link.lastSeen) > ttl {
+			events = append(events, graph.removeLink(k))
+		}
+	}
+	for isdAs, seen := range graph.seen {
+		if !graph.static[isdAs] && now.Sub(seen) > ttl {
+			events = append(events, graph.removeIsdAs(isdAs)...)
+		}
+	}
+	listeners := graph.removalListeners
+	graph.lock.Unlock()
+
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+	return events
+}
+
+// Removes the link. Without any other link between its ASes, they are disconnected.
+func (graph *NetworkGraph) removeLink(key LinkKey) RemovalEvent {
+	link := graph.links[key]
+	delete(graph.links, key)
+
+	parallel := false
+	for k := range graph.links {
+		if k.A == key.A && k.B == key.B {
+			parallel = true
+			break
+		}
+	}
+	if !parallel {
+		graph.disconnect(key.A, key.B)
+	}
+	return RemovalEvent{Link: key, LastSeen: link.lastSeen}
+}
+
+// Removes the AS with all its links.
+func (graph *NetworkGraph) removeIsdAs(isdAs addr.IA) []RemovalEvent {
+	var events []RemovalEvent
+	for k := range graph.links {
+		if k.A == isdAs || k.B == isdAs {
+			events = append(events, graph.removeLink(k))
+		}
+	}
+	for neighbor := range graph.nodes[isdAs].neighbors {
+		graph.disconnect(isdAs, neighbor)
+	}
+
+	events = append(events, RemovalEvent{IsdAs: isdAs, LastSeen: graph.seen[isdAs]})
+	delete(graph.nodes, isdAs)
+	delete(graph.seen, isdAs)
+	delete(graph.pathCounts, isdAs)
+	graph.size--
+	graph.structureVersion++
+	return events
+}
+
+// Removes the connection of both ASes and decreases their degrees by one.
+func (graph *NetworkGraph) disconnect(source addr.IA, target addr.IA) {
+	if !graph.connected(source, target) {
+		return
+	}
+	sourceNode := graph.nodes[source]
+	targetNode := graph.nodes[target]
+	delete(sourceNode.neighbors, target)
+	delete(targetNode.neighbors, source)
+	sourceNode.info.degree -= 1
+	targetNode.info.degree -= 1
+	graph.structureVersion++
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/scionproto/scion/go/lib/addr"
+	"testing"
+	"time"
+)
+
+// Test topology: 1-10 <-> 1-11 <-> 1-12, where 1-12 is not seen anymore
+func TestExpire(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	as112, _ := addr.IAFromString("1-12")
+	config := Default()
+	config.GraphTTL = 1
+	graph := Load(map[addr.IA][]addr.IA{as110: {as111}, as111: {as112}, as112: {}}, config)
+
+	var removed []RemovalEvent
+	graph.OnRemoval(func(event RemovalEvent) {
+		removed = append(removed, event)
+	})
+
+	now := time.Now()
+	graph.MarkLinkSeen(as110, 0, as111, 0, now.Add(90*time.Second))
+	events := graph.Expire(now.Add(2 * time.Minute))
+
+	if len(events) != 2 || len(removed) != 2 {
+		t.Fatalf("Expected the removal of 1-12 and its link, but was %v (listener: %v)", events, removed)
+	}
+	for _, event := range events {
+		if event.IsLink() && event.Link != NewLinkKey(as111, 0, as112, 0) {
+			t.Errorf("Unexpected removed link %v", event.Link)
+		} else if !event.IsLink() && event.IsdAs != as112 {
+			t.Errorf("Unexpected removed AS %v", event.IsdAs)
+		}
+	}
+
+	snapshot := graph.Snapshot()
+	if snapshot.Size() != 2 || graph.Contains(as112) || len(snapshot.Links()) != 1 {
+		t.Errorf("Expected 1-10 and 1-11 with one link, but was %v", snapshot.nodes)
+	}
+	if degree := snapshot.nodes[as111].info.degree; degree != 1 {
+		t.Errorf("Expected degree 1 of 1-11, but was %v", degree)
+	}
+	if neighbors := snapshot.Neighbors(as111); len(neighbors) != 1 || neighbors[0] != as110 {
+		t.Errorf("Expected neighbor 1-10 of 1-11, but was %v", neighbors)
+	}
+}
+
+func TestExpireDisabled(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	config := Default()
+	config.GraphTTL = 0
+	graph := Load(map[addr.IA][]addr.IA{as110: {as111}, as111: {}}, config)
+
+	if events := graph.Expire(time.Now().Add(24 * time.Hour)); len(events) != 0 || graph.Size() != 2 {
+		t.Errorf("Expected nothing to expire, but was %v", events)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// The graph in a tool independent form for the export to DOT, GraphML and node-link JSON.
+type ExportGraph struct {
+	Nodes []ExportNode
+	Links []ExportLink
+}
+
+type ExportNode struct {
+	Id             string
+	Degree         uint
+	CandidateScore float64
+	// Average measured bandwidth of the previous episodes in bytes per second
+	Activity float64
+	// Bytes per second
+	Capacity       float64
+	Betweenness    float64
+	PathCentrality float64
+}
+
+type ExportLink struct {
+	Source     string
+	Target     string
+	SourceIfId int
+	TargetIfId int
+	Type       LinkType
+	// Bytes per second
+	Capacity float64
+	// Average measured bandwidth of the previous episodes in bytes per second in both directions
+	SourceToTarget float64
+	TargetToSource float64
+}
+
+// The supported export formats.
+var ExportFormats = []string{"dot", "graphml", "json"}
+
+// Exports the current state of the graph. Without a selection, all ASes of the graph are scored as candidates.
+func (graph *NetworkGraph) Export() *ExportGraph {
+	snapshot := graph.Snapshot()
+	candidates := make(map[addr.IA]InspectionResultCandidate)
+	for k, v := range Create(graph.config).scoreCandidates(snapshot.nodes) {
+		candidates[k] = InspectionResultCandidate{Score: v.score, Raw: v.raw, Normalized: v.features}
+	}
+	nodes, links := inspectionGraph(snapshot, candidates)
+	return exportGraph(nodes, links)
+}
+
+// Exports the graph of a saved inspection result. Results without links, written before links were part of them,
+// get a link without interfaces for every pair of neighbors.
+func (result *InspectionResult) ExportGraph() *ExportGraph {
+	links := result.Links
+	if len(links) == 0 {
+		for k, v := range result.Graph {
+			for _, neighbor := range v.Neighbors {
+				if isdAsLess(k, neighbor) {
+					links = append(links, InspectionResultLink{Source: k, Target: neighbor})
+				}
+			}
+		}
+	}
+	return exportGraph(result.Graph, links)
+}
+
+func exportGraph(nodes map[addr.IA]InspectionResultGraphNode, links []InspectionResultLink) *ExportGraph {
+	isdAses := make([]addr.IA, 0, len(nodes))
+	for k := range nodes {
+		isdAses = append(isdAses, k)
+	}
+	sortIsdAses(isdAses)
+
+	graph := new(ExportGraph)
+	for _, k := range isdAses {
+		v := nodes[k]
+		node := ExportNode{Id: k.String(), Degree: v.Degree, CandidateScore: v.CandidateScore,
+			Capacity: float64(v.Capacity), Betweenness: v.Betweenness, PathCentrality: v.PathCentrality}
+		for _, act := range v.Activities {
+			node.Activity += float64(act.Bandwidth) / float64(len(v.Activities))
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	// Links with the same key, like two links of unknown interfaces between the same ASes of an older result, are
+	// all kept in their order
+	sorted := make([]InspectionResultLink, len(links))
+	copy(sorted, links)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return linkKeyLess(sorted[i].key(), sorted[j].key())
+	})
+	for _, v := range sorted {
+		link := ExportLink{Source: v.Source.String(), Target: v.Target.String(), SourceIfId: v.SourceIfId,
+			TargetIfId: v.TargetIfId, Type: v.Type, Capacity: float64(v.Capacity)}
+		for _, act := range v.Activities {
+			link.SourceToTarget += float64(act.SourceToTarget) / float64(len(v.Activities))
+			link.TargetToSource += float64(act.TargetToSource) / float64(len(v.Activities))
+		}
+		graph.Links = append(graph.Links, link)
+	}
+	return graph
+}
+
+func (link InspectionResultLink) key() LinkKey {
+	return NewLinkKey(link.Source, link.SourceIfId, link.Target, link.TargetIfId)
+}
+
+// Writes the graph in the format, one of ExportFormats.
+func (graph *ExportGraph) Write(w io.Writer, format string) error {
+	switch format {
+	case "dot":
+		return graph.WriteDot(w)
+	case "graphml":
+		return graph.WriteGraphML(w)
+	case "json":
+		return graph.WriteNodeLinkJson(w)
+	default:
+		return errors.New(fmt.Sprintf("Unsupported export format '%v', supported are %v", format, ExportFormats))
+	}
+}
+
+// Writes the graph as undirected Graphviz DOT graph. Parallel links are separate edges.
+func (graph *ExportGraph) WriteDot(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprintln(writer, "graph speedcam {")
+	for _, v := range graph.Nodes {
+		fmt.Fprintf(writer, "  %v [degree=%v, score=%v, activity=%v, capacity=%v, betweenness=%v, "+
+			"path_centrality=%v];\n", strconv.Quote(v.Id), v.Degree, formatFloat(v.CandidateScore),
+			formatFloat(v.Activity), formatFloat(v.Capacity), formatFloat(v.Betweenness), formatFloat(v.PathCentrality))
+	}
+	for _, v := range graph.Links {
+		fmt.Fprintf(writer, "  %v -- %v [source_ifid=%v, target_ifid=%v, type=%v, capacity=%v, "+
+			"bandwidth_source_target=%v, bandwidth_target_source=%v];\n", strconv.Quote(v.Source),
+			strconv.Quote(v.Target), v.SourceIfId, v.TargetIfId, strconv.Quote(string(v.Type)),
+			formatFloat(v.Capacity), formatFloat(v.SourceToTarget), formatFloat(v.TargetToSource))
+	}
+	fmt.Fprintln(writer, "}")
+	return writer.Flush()
+}
+
+type graphMLKey struct {
+	id       string
+	domain   string
+	name     string
+	dataType string
+}
+
+var graphMLKeys = []graphMLKey{
+	{"d0", "node", "degree", "int"},
+	{"d1", "node", "score", "double"},
+	{"d2", "node", "activity", "double"},
+	{"d3", "node", "capacity", "double"},
+	{"d4", "edge", "source_ifid", "int"},
+	{"d5", "edge", "target_ifid", "int"},
+	{"d6", "edge", "type", "string"},
+	{"d7", "edge", "capacity", "double"},
+	{"d8", "edge", "bandwidth_source_target", "double"},
+	{"d9", "edge", "bandwidth_target_source", "double"},
+	{"d10", "node", "betweenness", "double"},
+	{"d11", "node", "path_centrality", "double"},
+}
+
+// Writes the graph as undirected GraphML graph.
+func (graph *ExportGraph) WriteGraphML(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprintln(writer, xml.Header+`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	for _, v := range graphMLKeys {
+		fmt.Fprintf(writer, "  <key id=\"%v\" for=\"%v\" attr.name=\"%v\" attr.type=\"%v\"/>\n", v.id, v.domain,
+			v.name, v.dataType)
+	}
+	fmt.Fprintln(writer, `  <graph id="speedcam" edgedefault="undirected">`)
+	for _, v := range graph.Nodes {
+		fmt.Fprintf(writer, "    <node id=\"%v\">\n", escapeXml(v.Id))
+		writeGraphMLData(writer, "d0", strconv.FormatUint(uint64(v.Degree), 10))
+		writeGraphMLData(writer, "d1", formatFloat(v.CandidateScore))
+		writeGraphMLData(writer, "d2", formatFloat(v.Activity))
+		writeGraphMLData(writer, "d3", formatFloat(v.Capacity))
+		writeGraphMLData(writer, "d10", formatFloat(v.Betweenness))
+		writeGraphMLData(writer, "d11", formatFloat(v.PathCentrality))
+		fmt.Fprintln(writer, "    </node>")
+	}
+	for _, v := range graph.Links {
+		fmt.Fprintf(writer, "    <edge source=\"%v\" target=\"%v\">\n", escapeXml(v.Source), escapeXml(v.Target))
+		writeGraphMLData(writer, "d4", strconv.Itoa(v.SourceIfId))
+		writeGraphMLData(writer, "d5", strconv.Itoa(v.TargetIfId))
+		writeGraphMLData(writer, "d6", string(v.Type))
+		writeGraphMLData(writer, "d7", formatFloat(v.Capacity))
+		writeGraphMLData(writer, "d8", formatFloat(v.SourceToTarget))
+		writeGraphMLData(writer, "d9", formatFloat(v.TargetToSource))
+		fmt.Fprintln(writer, "    </edge>")
+	}
+	fmt.Fprintln(writer, "  </graph>")
+	fmt.Fprintln(writer, "</graphml>")
+	return writer.Flush()
+}
+
+func writeGraphMLData(writer io.Writer, key string, value string) {
+	fmt.Fprintf(writer, "      <data key=\"%v\">%v</data>\n", key, escapeXml(value))
+}
+
+func escapeXml(s string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(s))
+	return buffer.String()
+}
+
+type nodeLinkGraph struct {
+	Directed   bool                     `json:"directed"`
+	Multigraph bool                     `json:"multigraph"`
+	Graph      map[string]interface{}   `json:"graph"`
+	Nodes      []map[string]interface{} `json:"nodes"`
+	Links      []map[string]interface{} `json:"links"`
+}
+
+// Writes the graph in the node-link JSON format, as read by D3 and NetworkX.
+func (graph *ExportGraph) WriteNodeLinkJson(w io.Writer) error {
+	result := nodeLinkGraph{Multigraph: true, Graph: map[string]interface{}{"name": "speedcam"},
+		Nodes: make([]map[string]interface{}, 0, len(graph.Nodes)),
+		Links: make([]map[string]interface{}, 0, len(graph.Links))}
+	for _, v := range graph.Nodes {
+		result.Nodes = append(result.Nodes, map[string]interface{}{"id": v.Id, "degree": v.Degree,
+			"score": v.CandidateScore, "activity": v.Activity, "capacity": v.Capacity,
+			"betweenness": v.Betweenness, "path_centrality": v.PathCentrality})
+	}
+	for _, v := range graph.Links {
+		result.Links = append(result.Links, map[string]interface{}{"source": v.Source, "target": v.Target,
+			"source_ifid": v.SourceIfId, "target_ifid": v.TargetIfId, "type": v.Type, "capacity": v.Capacity,
+			"bandwidth_source_target": v.SourceToTarget, "bandwidth_target_source": v.TargetToSource})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
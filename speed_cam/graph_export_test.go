@@ -0,0 +1,130 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createExportTestGraph() *NetworkGraph {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	graph := Load(map[addr.IA][]addr.IA{as110: {as111}, as111: {}}, Default())
+	start := time.Date(2018, 02, 23, 10, 0, 0, 0, time.UTC)
+	graph.AddBandwidth(as110, start, time.Minute, 2000)
+	graph.AddBandwidth(as110, start.Add(time.Hour), time.Minute, 4000)
+	graph.AddLinkBandwidth(as111, 1, "", as110, start, time.Minute, 2*datasize.KB, datasize.KB)
+	return graph
+}
+
+func TestExportGraph(t *testing.T) {
+	graph := createExportTestGraph().Export()
+
+	if len(graph.Nodes) != 2 || len(graph.Links) != 1 {
+		t.Fatalf("Expected 2 nodes and 1 link, but was %v", graph)
+	}
+	node := graph.Nodes[0]
+	if node.Id != "1-10" || node.Degree != 1 || node.Activity != 3000 || node.CandidateScore == 0 {
+		t.Errorf("Unexpected node %v", node)
+	}
+	link := graph.Links[0]
+	if link.Source != "1-10" || link.TargetIfId != 1 || link.SourceToTarget != 1024 || link.TargetToSource != 2048 {
+		t.Errorf("Unexpected link %v", link)
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	graph := createExportTestGraph().Export()
+
+	var dot bytes.Buffer
+	if err := graph.Write(&dot, "dot"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot.String(), `"1-10" -- "1-11" [source_ifid=0, target_ifid=1`) {
+		t.Errorf("Expected the link in DOT, but was %v", dot.String())
+	}
+
+	var graphML bytes.Buffer
+	if err := graph.Write(&graphML, "graphml"); err != nil {
+		t.Fatal(err)
+	}
+	var parsedGraphML struct {
+		Nodes []struct {
+			Id string `xml:"id,attr"`
+		} `xml:"graph>node"`
+		Edges []struct {
+			Source string `xml:"source,attr"`
+		} `xml:"graph>edge"`
+	}
+	if err := xml.Unmarshal(graphML.Bytes(), &parsedGraphML); err != nil {
+		t.Fatalf("Invalid GraphML: %v", err)
+	}
+	if len(parsedGraphML.Nodes) != 2 || len(parsedGraphML.Edges) != 1 || parsedGraphML.Nodes[1].Id != "1-11" {
+		t.Errorf("Unexpected GraphML %v", graphML.String())
+	}
+
+	var nodeLink bytes.Buffer
+	if err := graph.Write(&nodeLink, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var parsedNodeLink nodeLinkGraph
+	if err := json.Unmarshal(nodeLink.Bytes(), &parsedNodeLink); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(parsedNodeLink.Nodes) != 2 || parsedNodeLink.Links[0]["target"] != "1-11" {
+		t.Errorf("Unexpected node-link JSON %v", nodeLink.String())
+	}
+
+	if err := graph.Write(&nodeLink, "svg"); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+// Results written before links were part of them still have the neighbors
+func TestExportResultWithoutLinks(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	result := InspectionResult{Graph: map[addr.IA]InspectionResultGraphNode{
+		as110: {Degree: 1, Neighbors: []addr.IA{as111}},
+		as111: {Degree: 1, Neighbors: []addr.IA{as110}},
+	}}
+
+	graph := result.ExportGraph()
+	if len(graph.Nodes) != 2 || len(graph.Links) != 1 || graph.Links[0].Source != "1-10" {
+		t.Errorf("Expected 2 nodes and the link 1-10<->1-11, but was %v", graph)
+	}
+}
+
+// Links of unknown interfaces between the same ASes are all exported
+func TestExportDuplicateLinks(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	result := InspectionResult{Graph: map[addr.IA]InspectionResultGraphNode{as110: {}, as111: {}},
+		Links: []InspectionResultLink{{Source: as111, Target: as110, Capacity: 2}, {Source: as110, Target: as111,
+			Capacity: 1}}}
+
+	graph := result.ExportGraph()
+	if len(graph.Links) != 2 || graph.Links[0].Capacity != 2 || graph.Links[1].Capacity != 1 {
+		t.Errorf("Expected both links in their order, but was %v", graph.Links)
+	}
+}
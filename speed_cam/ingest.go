@@ -0,0 +1,184 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// The paths of the ingest endpoint. The path requests and BR information are POSTed as JSON array, in the same
+// format as fetched by PathRequestRestFetcher and PrometheusClientFetcher.
+const (
+	IngestPathRequests = "/pathServerRequests"
+	IngestBrInfo       = "/prometheusClient"
+	IngestStatus       = "/status"
+)
+
+// The largest accepted body of a push in bytes.
+const maxIngestSize = 10 << 20
+
+// The answer to pushed data.
+type IngestResult struct {
+	Accepted int
+	// Path requests which could not be parsed and incomplete BR information
+	Rejected int
+	// The error of the last rejected entry
+	err error
+}
+
+// Creates the handler of the ingest endpoint. Pushed path requests and BR information update the graph like fetched
+// ones. The status of the fetched and pushed sources is served as JSON with GET on IngestStatus.
+func (inspector *Inspector) IngestHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(IngestPathRequests, func(w http.ResponseWriter, r *http.Request) {
+		var pathRequests []string
+		if !decodeIngest(w, r, &pathRequests) {
+			return
+		}
+		result := IngestResult{}
+		for _, v := range pathRequests {
+			if err := inspector.HandlePathRequest(v); err != nil {
+				MyLogger.Warningf("Ignored pushed path request, err: %v\n", err)
+				result.reject(err)
+			} else {
+				result.Accepted++
+			}
+		}
+		MyLogger.Debugf("Handled %v pushed path requests\n", len(pathRequests))
+		inspector.updatePushStatus(PushSourcePathRequests, result)
+		writeIngestJson(w, result)
+	})
+	mux.HandleFunc(IngestBrInfo, func(w http.ResponseWriter, r *http.Request) {
+		var infos []PrometheusClientInfo
+		if !decodeIngest(w, r, &infos) {
+			return
+		}
+		result := IngestResult{}
+		accepted := make([]PrometheusClientInfo, 0, len(infos))
+		for _, v := range infos {
+			if err := validateBrInfo(v); err != nil {
+				MyLogger.Warningf("Ignored pushed border router information, err: %v\n", err)
+				result.reject(err)
+			} else {
+				accepted = append(accepted, v)
+				result.Accepted++
+			}
+		}
+		if len(accepted) > 0 {
+			inspector.AddBrInfo(accepted)
+		}
+		MyLogger.Debugf("Added %v pushed border router information\n", len(accepted))
+		inspector.updatePushStatus(PushSourceBrInfo, result)
+		writeIngestJson(w, result)
+	})
+	mux.HandleFunc(IngestStatus, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		writeIngestJson(w, inspector.FetchStatus())
+	})
+	return mux
+}
+
+func (result *IngestResult) reject(err error) {
+	result.Rejected++
+	result.err = err
+}
+
+// Records a push with an accepted entry as success and a push with only rejected entries as error. An empty push
+// does not change the status.
+func (inspector *Inspector) updatePushStatus(source string, result IngestResult) {
+	var err error
+	if result.Accepted == 0 {
+		if result.Rejected == 0 {
+			return
+		}
+		err = errors.New(fmt.Sprintf("rejected all %v pushed entries, last err: %v", result.Rejected, result.err))
+	}
+	inspector.fetchStatuses.update(source, err, time.Now(), time.Time{})
+}
+
+// Checks that pushed BR information can be polled and is about a link between two ASes.
+func validateBrInfo(info PrometheusClientInfo) error {
+	if info.Ip == "" || info.Port <= 0 || info.Port > 65535 {
+		return errors.New(fmt.Sprintf("Invalid address '%v:%v' of %v", info.Ip, info.Port, info.SourceIsdAs))
+	}
+	if info.SourceIsdAs.IsZero() || info.TargetIsdAs.IsZero() {
+		return errors.New(fmt.Sprintf("Missing AS of the link %v -> %v", info.SourceIsdAs, info.TargetIsdAs))
+	}
+	return nil
+}
+
+// Decodes the POSTed JSON body. Answers with an error and returns false for other methods, bodies larger than
+// maxIngestSize and invalid JSON.
+func decodeIngest(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return false
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestSize))
+	if err != nil {
+		MyLogger.Warningf("Ignored too large push to %v, err: %v\n", r.URL.Path, err)
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	err = json.Unmarshal(body, target)
+	if err != nil {
+		MyLogger.Warningf("Ignored invalid push to %v, err: %v\n", r.URL.Path, err)
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeIngestJson(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		MyLogger.Errorf("error writing ingest answer, err: %v", err)
+	}
+}
+
+// Listens on the ingest address of the config until the context is cancelled.
+func (inspector *Inspector) ServeIngest(ctx context.Context) error {
+	server := &http.Server{Addr: inspector.config.IngestAddress, Handler: inspector.IngestHandler()}
+	// Stops closing the server on cancellation if it could not listen
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			server.Close()
+		case <-stopped:
+		}
+	}()
+
+	MyLogger.Infof("Listening for pushed path requests and border router information on %v",
+		inspector.config.IngestAddress)
+	err := server.ListenAndServe()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	MyLogger.Criticalf("error serving ingest endpoint, err: %v", err)
+	return err
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"encoding/json"
+	"github.com/scionproto/scion/go/lib/addr"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIngestPathRequests(t *testing.T) {
+	inspector := CreateEmptyGraph(Default())
+	server := httptest.NewServer(inspector.IngestHandler())
+	defer server.Close()
+
+	body := `["1-10 1>2 1-11", "1-11 x 1-12"]`
+	resp, err := http.Post(server.URL+IngestPathRequests, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result IngestResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || result.Accepted != 1 || result.Rejected != 1 {
+		t.Errorf("Expected one accepted and one rejected path request, but was %v (%v)", result, resp.Status)
+	}
+	if inspector.graph.size != 2 {
+		t.Errorf("Expected graph with two ASes, but contains %v", inspector.graph.size)
+	}
+	if status := inspector.FetchStatus(); len(status) != 1 || status[0].Source != PushSourcePathRequests {
+		t.Errorf("Expected the status of the path requests, but was %v", status)
+	}
+
+	resp, err = http.Post(server.URL+IngestPathRequests, "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected bad request for invalid JSON, but was %v", resp.Status)
+	}
+
+	body = "[" + strings.Repeat(`"1-10 1>2 1-11",`, maxIngestSize/16) + `"1-10 1>2 1-11"]`
+	resp, err = http.Post(server.URL+IngestPathRequests, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected request entity too large, but was %v", resp.Status)
+	}
+}
+
+// A push of only invalid entries is an error of the source
+func TestIngestRejected(t *testing.T) {
+	inspector := CreateEmptyGraph(Default())
+	server := httptest.NewServer(inspector.IngestHandler())
+	defer server.Close()
+
+	body := `[{"Ip": "", "Port": 1, "SourceIsdAs": "1-10", "TargetIsdAs": "1-11", "IfId": 1},
+		{"Ip": "127.0.0.1", "Port": 1, "SourceIsdAs": "1-10", "IfId": 2}]`
+	resp, err := http.Post(server.URL+IngestBrInfo, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result IngestResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Accepted != 0 || result.Rejected != 2 || len(inspector.BrInfo()) != 0 {
+		t.Errorf("Expected both BR information rejected, but was %v and %v", result, inspector.BrInfo())
+	}
+	status := inspector.FetchStatus()
+	if len(status) != 1 || status[0].Failures != 1 || status[0].Error == "" || !status[0].LastSuccess.IsZero() {
+		t.Errorf("Expected a failed push, but was %v", status)
+	}
+}
+
+// Pushed information replaces the one about the same interface, also the fetched one
+func TestIngestBrInfo(t *testing.T) {
+	inspector := CreateEmptyGraph(Default())
+	as110, _ := addr.IAFromString("1-10")
+	as113, _ := addr.IAFromString("1-13")
+	inspector.setBrInfo([]PrometheusClientInfo{{Ip: "127.0.0.3", SourceIsdAs: as110, TargetIsdAs: as113, IfId: 3},
+		{Ip: "127.0.0.3", SourceIsdAs: as110, TargetIsdAs: as113, IfId: 2}})
+	server := httptest.NewServer(inspector.IngestHandler())
+	defer server.Close()
+
+	push := func(body string) {
+		resp, err := http.Post(server.URL+IngestBrInfo, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected OK, but was %v", resp.Status)
+		}
+	}
+	push(`[{"Ip": "127.0.0.1", "Port": 1, "SourceIsdAs": "1-10", "TargetIsdAs": "1-11", "IfId": 1},
+		{"Ip": "127.0.0.1", "Port": 2, "SourceIsdAs": "1-10", "TargetIsdAs": "1-12", "IfId": 2}]`)
+	push(`[{"Ip": "127.0.0.2", "Port": 1, "SourceIsdAs": "1-10", "TargetIsdAs": "1-11", "IfId": 1}]`)
+
+	as111, _ := addr.IAFromString("1-11")
+	infos := inspector.BrInfo()
+	if len(infos) != 3 || infos[0].IfId != 3 || infos[2].Ip != "127.0.0.2" || infos[2].TargetIsdAs != as111 {
+		t.Errorf("Expected the fetched interface 3, the replaced interface 1 and interface 2, but was %v", infos)
+	}
+
+	// A fetch does not discard the pushed information
+	fetched := []PrometheusClientInfo{{Ip: "127.0.0.4", SourceIsdAs: as110, TargetIsdAs: as113, IfId: 4}}
+	inspector.setBrInfo(fetched)
+	if infos = inspector.BrInfo(); len(infos) != 3 || infos[0].IfId != 4 {
+		t.Errorf("Expected the fetched interface 4 and the pushed interfaces, but was %v", infos)
+	}
+	// The next poll of the fetcher does not change the stored information
+	fetched[0].IfId = 5
+	if infos = inspector.BrInfo(); infos[0].IfId != 4 {
+		t.Errorf("Expected the stored interface 4, but was %v", infos[0])
+	}
+	if status := inspector.FetchStatus(); len(status) != 1 || status[0].Source != PushSourceBrInfo {
+		t.Errorf("Expected only the status of the pushed BR information, but was %v", status)
+	}
+
+	resp, err := http.Get(server.URL + IngestBrInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected method not allowed for GET, but was %v", resp.Status)
+	}
+}
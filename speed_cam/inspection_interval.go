@@ -29,7 +29,7 @@ func getWaitTime(inspector *Inspector) time.Duration {
 	case "fixed":
 		return time.Duration(config.IntervalWaitMin) * time.Second
 	case "random":
-		return calculateRandomWaitTime(config)
+		return calculateRandomWaitTime(config, inspector.intervalRandom)
 	case "experience":
 		return calculateExperiencedWaitTime(inspector)
 	default:
@@ -38,9 +38,9 @@ func getWaitTime(inspector *Inspector) time.Duration {
 	}
 }
 
-func calculateRandomWaitTime(config *SpeedCamConfig) time.Duration {
+func calculateRandomWaitTime(config *SpeedCamConfig, random *rand.Rand) time.Duration {
 
-	sleepTime := rand.Int63n(int64(config.IntervalWaitMax-config.IntervalWaitMin)) + int64(config.IntervalWaitMin)
+	sleepTime := random.Int63n(int64(config.IntervalWaitMax-config.IntervalWaitMin)) + int64(config.IntervalWaitMin)
 	return time.Duration(time.Duration(sleepTime) * time.Second)
 }
 
@@ -51,7 +51,7 @@ func calculateExperiencedWaitTime(inspector *Inspector) time.Duration {
 	}
 
 	// Calculate activity per timestamp for the complete day
-	for _, v := range inspector.graph.nodes {
+	for _, v := range inspector.graph.Snapshot().nodes {
 		v.info.activities.Do(func(x interface{}) {
 			if x == nil {
 				return
@@ -78,7 +78,7 @@ func calculateExperiencedWaitTime(inspector *Inspector) time.Duration {
 
 	if activeSlots < 5 {
 		MyLogger.Warningf("Only '%v' active time slots! Using random wait time instead until at least 5 slots.", activeSlots)
-		return calculateRandomWaitTime(inspector.config)
+		return calculateRandomWaitTime(inspector.config, inspector.intervalRandom)
 	}
 
 	// Sort by activity
@@ -29,52 +29,126 @@ import (
 )
 
 type InspectionResult struct {
-	SpeedCamResults []map[addr.IA][]SpeedCamResult
-	Start           time.Time
-	Duration        time.Duration
-	Graph           map[addr.IA]InspectionResultGraphNode
-	Config          SpeedCamConfig
+	SpeedCamResults []map[LinkKey]SpeedCamResults
+	// Statistics of each link in SpeedCamResults, same order and keys
+	Statistics []map[LinkKey]SpeedCamStatistics
+	Start      time.Time
+	Duration   time.Duration
+	// The inspection was cancelled before all SpeedCams finished their measurement
+	Incomplete bool
+	// Links measured by the selected SpeedCams, parallel links between the same ASes count each
+	CoveredLinks int
+	// How the selector scored the usable ASes and why they were selected or not
+	Candidates map[addr.IA]InspectionResultCandidate
+	Graph      map[addr.IA]InspectionResultGraphNode
+	// The links of the graph with their history
+	Links []InspectionResultLink
+	// Health of the path request and BR information sources at the end of the inspection
+	FetchStatus []FetchStatus
+	Config      SpeedCamConfig
 }
 
 type InspectionResultGraphNode struct {
-	Activities     []InspectionResultActivity
-	Capacity       datasize.ByteSize
+	Activities []InspectionResultActivity
+	Capacity   datasize.ByteSize
+	// The score of the selector, zero if the AS was no candidate
 	CandidateScore float64
 	Degree         uint
+	// Normalized betweenness centrality of the AS
+	Betweenness float64
+	// Share of the path requests whose path contains the AS
+	PathCentrality float64
+	// The features of the candidate score normalized across the candidates, zero if the AS was no candidate
+	Features ScoreFeatures
 
 	Neighbors []addr.IA
 }
 
+// The score of a candidate as calculated by the selector and the decision of the selection strategy.
+type InspectionResultCandidate struct {
+	// The score scaled to 0.0 - 1.0 across the candidates
+	Score float64
+	// The features before and after the score normalization
+	Raw        ScoreFeatures
+	Normalized ScoreFeatures
+	// The contributions of the normalized features to the score, before it is scaled
+	Weighted ScoreFeatures
+	Selected bool
+	Reason   SelectionReason
+	// The random number drawn for the candidate, only valid if Drawn
+	Draw  float64
+	Drawn bool
+}
+
+type InspectionResultLink struct {
+	Source     addr.IA
+	Target     addr.IA
+	SourceIfId int
+	TargetIfId int
+	SourceBrId string
+	TargetBrId string
+	// The relation of the target to the source, e.g. PARENT if the target is the parent of the source
+	Type       LinkType
+	Capacity   datasize.ByteSize
+	Activities []InspectionResultLinkActivity
+}
+
+type InspectionResultLinkActivity struct {
+	Start          time.Time
+	Duration       time.Duration
+	SourceToTarget datasize.ByteSize
+	TargetToSource datasize.ByteSize
+}
+
 type InspectionResultActivity struct {
 	Start     time.Time
 	Duration  time.Duration
 	Bandwidth datasize.ByteSize
 }
 
-func SerializableResult(inspector *Inspector, results []map[addr.IA][]SpeedCamResult, start time.Time,
-	duration time.Duration) *InspectionResult {
-	result := InspectionResult{Start: start, Duration: duration, SpeedCamResults: results, Config: *inspector.config}
+// Creates the result of an inspection. The graph nodes of the candidates get the scores the selector used, they are
+// only scored among the ASes with BR information, all other ASes stay unscored.
+func SerializableResult(inspector *Inspector, results []map[LinkKey]SpeedCamResults,
+	candidates map[addr.IA]InspectionResultCandidate, start time.Time, duration time.Duration) *InspectionResult {
+	result := InspectionResult{Start: start, Duration: duration, SpeedCamResults: results, Candidates: candidates,
+		Config: *inspector.config}
+	result.createStatistics()
 	result.createInspectionGraph(inspector)
+	result.FetchStatus = inspector.FetchStatus()
 	return &result
 }
 
+func (result *InspectionResult) createStatistics() {
+	result.Statistics = make([]map[LinkKey]SpeedCamStatistics, len(result.SpeedCamResults))
+	for i, m := range result.SpeedCamResults {
+		result.Statistics[i] = make(map[LinkKey]SpeedCamStatistics)
+		for k, v := range m {
+			result.Statistics[i][k] = v.Statistics()
+		}
+	}
+}
+
 func (result *InspectionResult) createInspectionGraph(inspector *Inspector) {
+	result.Graph, result.Links = inspectionGraph(inspector.graph.Snapshot(), result.Candidates)
+}
+
+// Converts the snapshot to the nodes and links of a result. Nodes without a candidate have no score.
+func inspectionGraph(snapshot *GraphSnapshot, candidates map[addr.IA]InspectionResultCandidate) (
+	map[addr.IA]InspectionResultGraphNode, []InspectionResultLink) {
 
-	selector := Create(inspector.config)
-	result.Graph = make(map[addr.IA]InspectionResultGraphNode)
+	graph := make(map[addr.IA]InspectionResultGraphNode)
 
-	for k, v := range inspector.graph.nodes {
+	for k, v := range snapshot.nodes {
 
 		node := InspectionResultGraphNode{}
-		var neighbors []addr.IA
-		for neighbor := range v.neighbors {
-			neighbors = append(neighbors, neighbor)
-		}
-		node.Neighbors = neighbors
-		node.CandidateScore = selector.calculateScore(v).score
+		node.Neighbors = snapshot.Neighbors(k)
+		node.CandidateScore = candidates[k].Score
+		node.Features = candidates[k].Normalized
 
 		node.Capacity = v.info.capacity
 		node.Degree = v.info.degree
+		node.Betweenness = v.info.betweenness
+		node.PathCentrality = v.info.pathCentrality
 
 		node.Activities = make([]InspectionResultActivity, 0)
 		v.info.activities.Do(func(x interface{}) {
@@ -86,8 +160,27 @@ func (result *InspectionResult) createInspectionGraph(inspector *Inspector) {
 			node.Activities = append(node.Activities, resultActivity)
 		})
 
-		result.Graph[k] = node
+		graph[k] = node
+	}
+
+	links := make([]InspectionResultLink, 0, len(snapshot.links))
+	for _, k := range snapshot.Links() {
+		v := snapshot.links[k]
+		link := InspectionResultLink{Source: k.A, Target: k.B, SourceIfId: k.IfIdA, TargetIfId: k.IfIdB,
+			SourceBrId: v.brIdA, TargetBrId: v.brIdB, Type: v.linkType, Capacity: v.capacity}
+
+		link.Activities = make([]InspectionResultLinkActivity, 0)
+		v.activities.Do(func(x interface{}) {
+			if x == nil {
+				return
+			}
+			act := x.(linkActivity)
+			link.Activities = append(link.Activities, InspectionResultLinkActivity{Start: act.start,
+				Duration: act.duration, SourceToTarget: act.bandwidthAB, TargetToSource: act.bandwidthBA})
+		})
+		links = append(links, link)
 	}
+	return graph, links
 }
 
 func (result *InspectionResult) writeJsonResult(dir string) {
@@ -16,12 +16,12 @@
 package speed_cam
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"github.com/c2h5oh/datasize"
 	"github.com/op/go-logging"
 	"github.com/scionproto/scion/go/lib/addr"
-	"regexp"
+	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -30,6 +30,20 @@ type Inspector struct {
 	config        *SpeedCamConfig
 	fetcher       PathRequestFetcher
 	brInfoFetcher PrometheusClientFetcher
+	// Polls the BRs for all SpeedCams
+	scheduler *PollScheduler
+	// Selects the SpeedCams of every episode
+	selector *SpeedCamSelector
+	// Sources of the random wait times between inspections and between failed fetches
+	intervalRandom *rand.Rand
+	fetchRandom    *rand.Rand
+	// Health of the path request and BR information sources
+	fetchStatuses fetchStatuses
+	// The BRs with their interfaces the SpeedCams can poll. A fetch replaces all fetched ones, so the pushed ones are
+	// kept apart
+	brInfoLock   sync.RWMutex
+	brInfo       []PrometheusClientInfo
+	pushedBrInfo []PrometheusClientInfo
 }
 
 // Creates an inspector with an empty to be explored network graph.
@@ -37,11 +51,26 @@ func CreateEmptyGraph(config *SpeedCamConfig) *Inspector {
 	return CreateWithGraph(config, CreateEmpty(config))
 }
 
-// Creates an inspector with an already existing graph. This graph can also be expanded by exploration
+// Creates an inspector with an already existing graph. This graph can also be expanded by exploration. The inspector
+// works on a copy of the config, so a chosen seed does not change the config of the caller.
 func CreateWithGraph(config *SpeedCamConfig, graph *NetworkGraph) *Inspector {
 	inspector := new(Inspector)
+	copied := *config
+	config = &copied
+	// Without a seed, the run can still be replayed with the seed written to the results
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	MyLogger.Infof("Random seed: %v", config.Seed)
 	inspector.config = config
 	inspector.graph = graph
+	inspector.scheduler = CreatePollSchedulerFromConfig(config)
+	inspector.selector = Create(config)
+	inspector.intervalRandom = newRandom(config.Seed, randomStreamInterval)
+	inspector.fetchRandom = newRandom(config.Seed, randomStreamFetch)
+	graph.OnRemoval(func(event RemovalEvent) {
+		MyLogger.Infof("Removed stale %v from the graph", event)
+	})
 
 	// Disable debug logging
 	if !config.Verbose {
@@ -51,98 +80,198 @@ func CreateWithGraph(config *SpeedCamConfig, graph *NetworkGraph) *Inspector {
 	return inspector
 }
 
-var isdAsRegex = regexp.MustCompile(`(\s*\d+>\d+\s*)`)
+// The network graph explored by the inspector.
+func (inspector *Inspector) Graph() *NetworkGraph {
+	return inspector.graph
+}
 
-// Handles a path request to update the network graph.
-// Input format is: ISD-AS /d>/dISD-AS
-// Example: 1-1 1>1 1-5 4>3 1-6 2>1 1-7
+// Handles a path request to update the network graph. The interfaces of the path request are stored on the links.
+// For the format see ParsePathRequest.
 func (inspector *Inspector) HandlePathRequest(pathRequest string) error {
 
-	isdPairs := isdAsRegex.Split(pathRequest,-1)
-	if isdPairs == nil {
-		return errors.New(fmt.Sprintf("Path request has invalid format or no pairs. Request:%s", pathRequest))
+	request, err := ParsePathRequest(pathRequest)
+	if err != nil {
+		return err
+	}
+	hops := request.Hops
+
+	// Add all ASes to the graph
+	for _, e := range hops {
+		inspector.graph.AddIsdAs(e.IsdAs)
 	}
 
-	var isdAses []addr.IA
-	for _, e := range isdPairs {
-		isdAs, err := addr.IAFromString(e)
+	// Connect ASes pair wise by their interfaces
+	for i := 0; i < len(hops)-1; i++ {
+		err := inspector.graph.ConnectInterfaces(hops[i].IsdAs, hops[i].EgressIfId, hops[i+1].IsdAs,
+			hops[i+1].IngressIfId)
 		if err != nil {
-			return errors.New(fmt.Sprintf("Path request has invalid format or no pairs. Request:%s", pathRequest))
+			return err
 		}
-		isdAses = append(isdAses, isdAs)
 	}
 
-	// Add all ASes to the graph
-	for _, e := range isdAses {
-		inspector.graph.AddIsdAs(e)
+	// Already known ASes and links are still alive
+	now := time.Now()
+	for _, e := range hops {
+		inspector.graph.MarkSeen(e.IsdAs, now)
+	}
+	for i := 0; i < len(hops)-1; i++ {
+		inspector.graph.MarkLinkSeen(hops[i].IsdAs, hops[i].EgressIfId, hops[i+1].IsdAs, hops[i+1].IngressIfId, now)
 	}
+	inspector.graph.AddPathRequest(request.IsdAses())
+
+	return nil
+}
 
-	// Connect ASes pair wise
-	for i := 0; i < len(isdAses)-1; i++ {
-		inspector.graph.ConnectIsdAses(isdAses[i], isdAses[i+1])
+// Loads the link capacities from the capacity file and topology dir of the config into the graph
+func (inspector *Inspector) LoadCapacities() error {
+	config := inspector.config
+	if len(config.CapacityFile) != 0 {
+		capacities, err := LoadCapacityFile(config.CapacityFile)
+		if err != nil {
+			return err
+		}
+		inspector.graph.AddCapacities(capacities)
+		MyLogger.Debugf("Loaded %v link capacities from '%v'", len(capacities), config.CapacityFile)
+	}
+	if len(config.TopologyDir) != 0 {
+		capacities, err := LoadTopologyCapacities(config.TopologyDir)
+		if err != nil {
+			return err
+		}
+		inspector.graph.AddCapacities(capacities)
+		MyLogger.Debugf("Loaded %v link capacities from '%v'", len(capacities), config.TopologyDir)
 	}
+	return nil
+}
 
+// Adds the ASes and links of the topology dir of the config to the graph, if bootstrapping is enabled.
+func (inspector *Inspector) LoadTopology() error {
+	config := inspector.config
+	if !config.TopologyBootstrap || len(config.TopologyDir) == 0 {
+		return nil
+	}
+	interfaces, err := LoadTopologyDir(config.TopologyDir)
+	if err != nil {
+		return err
+	}
+	inspector.graph.AddTopology(interfaces)
+	MyLogger.Debugf("Loaded %v interfaces from '%v', the graph has %v ASes", len(interfaces), config.TopologyDir,
+		inspector.graph.Size())
 	return nil
 }
 
-func (inspector *Inspector) Start(fetcher PathRequestFetcher, clientFetcher PrometheusClientFetcher) error {
+// Starts fetching path requests and border router information till the context is cancelled.
+func (inspector *Inspector) Start(ctx context.Context, fetcher PathRequestFetcher,
+	clientFetcher PrometheusClientFetcher) error {
 
 	inspector.fetcher = fetcher
 	inspector.brInfoFetcher = clientFetcher
 
-	go inspector.fetchPathRequests()
-	go inspector.fetchBrInfo()
+	// Without a fetcher, the data is only pushed to the ingest endpoint
+	if fetcher != nil {
+		go inspector.fetchPathRequests(ctx)
+	}
+	if len(clientFetcher.FetcherResource) != 0 {
+		go inspector.fetchBrInfo(ctx)
+	}
+	if len(inspector.config.IngestAddress) != 0 {
+		go inspector.ServeIngest(ctx)
+	}
+	if len(inspector.config.StateFile) != 0 && inspector.config.StateInterval > 0 {
+		go inspector.checkpointPeriodically(ctx)
+	}
 
 	return nil
 }
 
-func (inspector *Inspector) StartInspection() {
+// Runs the SpeedCams of one episode. When the context is cancelled, the SpeedCams stop early and the partial results
+// are written marked as incomplete.
+func (inspector *Inspector) StartInspection(ctx context.Context) {
 
 	startTime := time.Now()
 	MyLogger.Info("Start inspection!")
-	if inspector.graph.size == 0 {
+	inspector.warnStaleSources(startTime)
+	// Stale ASes must not be selected
+	inspector.graph.Expire(startTime)
+	// The path requests keep changing the graph while selecting
+	snapshot := inspector.graph.Snapshot()
+	if snapshot.Size() == 0 {
 		MyLogger.Warning("Network graph is empty (as far as I know). Inspection aborted.")
 		return
 	}
 
-	selector := Create(inspector.config)
-	clientInfos := inspector.brInfoFetcher.Info
+	clientInfos := inspector.BrInfo()
 	clientInfoGrouped := groupBySource(clientInfos)
-	usableSpeedCams := filterNodesWithBrInfos(clientInfoGrouped, inspector.graph.nodes)
+	usableSpeedCams := filterNodesWithBrInfos(clientInfoGrouped, snapshot.nodes)
 
-	MyLogger.Debugf("Existing nodes in the graph: %v, nodes with BR information: %v", inspector.graph.size, len(usableSpeedCams))
-	selectSpeedCams := selector.SelectUsableSpeedCams(usableSpeedCams)
+	MyLogger.Debugf("Existing nodes in the graph: %v, nodes with BR information: %v", snapshot.Size(), len(usableSpeedCams))
+	selectSpeedCams, candidates := inspector.selector.SelectAndExplain(usableSpeedCams, snapshot.links)
+	covered := coveredLinks(selectSpeedCams, snapshot.links)
+	MyLogger.Infof("Selected %v SpeedCams covering %v links", len(selectSpeedCams), covered)
 
 	size := len(selectSpeedCams)
-	resultChannel := make(chan map[addr.IA][]SpeedCamResult, size)
+	resultChannel := make(chan map[LinkKey]SpeedCamResults, size)
 	defer close(resultChannel)
 
-	inspectionDuration := 30 * time.Second
+	pollInterval := time.Duration(inspector.config.PollInterval) * time.Second
 	for _, selectedSpeedCam := range selectSpeedCams {
 		MyLogger.Debugf("Initiate speed cam on '%v'\n", selectedSpeedCam.IsdAs)
 		info := clientInfoGrouped[selectedSpeedCam.IsdAs]
-		speedCam := CreateSpeedCam(selectedSpeedCam.IsdAs, inspectionDuration)
-		MyLogger.Debugf("Start speed cam on '%v' for %v \n", selectedSpeedCam.IsdAs, inspectionDuration)
+		speedCam := inspector.createSpeedCam(selectedSpeedCam.IsdAs)
+		MyLogger.Debugf("Start speed cam on '%v' for %v - %v \n", selectedSpeedCam.IsdAs, speedCam.duration,
+			speedCam.maxDuration)
 
-		go func(cam *SpeedCam, c chan map[addr.IA][]SpeedCamResult) {
-			c <- cam.Measure(info, 5*time.Second)
+		go func(cam *SpeedCam, c chan map[LinkKey]SpeedCamResults) {
+			c <- cam.Measure(ctx, info, pollInterval)
 		}(speedCam, resultChannel)
 	}
 
-	var inspectionResults []map[addr.IA][]SpeedCamResult
+	var inspectionResults []map[LinkKey]SpeedCamResults
 	for i := 0; i < size; i++ {
 		inspectionResults = append(inspectionResults, <-resultChannel)
 	}
+	// Adaptive measurements take as long as the longest SpeedCam
+	inspectionDuration := time.Since(startTime)
+	incomplete := ctx.Err() != nil
+	if incomplete {
+		MyLogger.Warning("Inspection cancelled, the results are incomplete")
+	} else {
+		// Detect before aggregating, so the current results are not part of the history yet. A cancelled
+		// measurement is too short to judge a congestion
+		inspector.detectCongestions(selectSpeedCams, inspectionResults)
+	}
 	inspector.aggregateResults(inspectionResults, startTime, inspectionDuration)
 	presentResults(inspectionResults)
 	// If a result dir was specified -> write results to it
 	if len(inspector.config.ResultDir) != 0 {
-		serializeResult := SerializableResult(inspector, inspectionResults, startTime, inspectionDuration)
+		serializeResult := SerializableResult(inspector, inspectionResults, candidates, startTime, inspectionDuration)
+		serializeResult.Incomplete = incomplete
+		serializeResult.CoveredLinks = covered
 		serializeResult.writeJsonResult(inspector.config.ResultDir)
 	}
 	MyLogger.Info("Inspection finished!")
 }
 
+// Creates a SpeedCam measuring as configured by the measurement strategy
+func (inspector *Inspector) createSpeedCam(isdAs addr.IA) *SpeedCam {
+	config := inspector.config
+	minDuration := time.Duration(config.MeasurementDuration) * time.Second
+
+	var speedCam *SpeedCam
+	switch config.MeasurementStrategy {
+	case "fixed":
+		speedCam = CreateSpeedCam(isdAs, minDuration)
+	case "adaptive":
+		maxDuration := time.Duration(config.MeasurementDurationMax) * time.Second
+		speedCam = CreateAdaptiveSpeedCam(isdAs, minDuration, maxDuration, config.MeasurementVariation)
+	default:
+		MyLogger.Panicf("Unsupported measurement strategy '%v'", config.MeasurementStrategy)
+		return nil
+	}
+	speedCam.scheduler = inspector.scheduler
+	return speedCam
+}
+
 func filterNodesWithBrInfos(clientInfo map[addr.IA][]PrometheusClientInfo, nodes map[addr.IA]networkNode) map[addr.IA]networkNode {
 	filteredMap := make(map[addr.IA]networkNode)
 
@@ -156,7 +285,7 @@ func filterNodesWithBrInfos(clientInfo map[addr.IA][]PrometheusClientInfo, nodes
 	return filteredMap
 }
 
-func presentResults(results []map[addr.IA][]SpeedCamResult) {
+func presentResults(results []map[LinkKey]SpeedCamResults) {
 
 	for i := 0; i < len(results); i++ {
 		measureResults := results[i]
@@ -164,42 +293,58 @@ func presentResults(results []map[addr.IA][]SpeedCamResult) {
 		for k, v := range measureResults {
 			MyLogger.Debugf("\tResults for %v:\n", k)
 			for _, result := range v {
-				MyLogger.Debugf("\t\tLink: %v<->%v Timestamp: %v, In: %v/s, Out: %v/s\n",
-					result.Neighbor, result.Source, result.Timestamp, result.BandwidthIn.HR(), result.BandwidthOut.HR())
+				MyLogger.Debugf("\t\tLink: %v<->%v Timestamp: %v, In: %v/s, Out: %v/s, Packets in: %.2f/s, "+
+					"Packets out: %.2f/s, Dropped: %.2f/s, Errors: %.2f/s, Packet size in: %.0f B, "+
+					"Packet size out: %.0f B, Reset: %v\n",
+					result.Neighbor, result.Source, result.Timestamp, datasize.ByteSize(result.BandwidthIn).HR(),
+					datasize.ByteSize(result.BandwidthOut).HR(), result.PacketsIn, result.PacketsOut, result.DroppedIn,
+					result.Errors(), result.PacketSizesIn.Mean(), result.PacketSizesOut.Mean(), result.Reset)
 			}
 		}
 	}
 }
 
-func (inspector *Inspector) aggregateResults(results []map[addr.IA][]SpeedCamResult, start time.Time,
+// Records for every selected SpeedCam whether its results show a congestion
+func (inspector *Inspector) detectCongestions(speedCams []networkNode, results []map[LinkKey]SpeedCamResults) {
+
+	detector := CreateDetector(inspector.config, inspector.graph)
+	for _, speedCam := range speedCams {
+		resultsPerLink := make(map[LinkKey]SpeedCamResults)
+		for _, m := range results {
+			for k, v := range m {
+				if len(v) > 0 && v[0].Source == speedCam.IsdAs {
+					resultsPerLink[k] = v
+				}
+			}
+		}
+
+		detected := detector.Detect(speedCam.info, resultsPerLink)
+		MyLogger.Debugf("Congestion detected by speed cam on '%v': %v", speedCam.IsdAs, detected)
+		err := inspector.graph.AddDetectionResult(speedCam.IsdAs, detected)
+		if err != nil {
+			MyLogger.Errorf("error adding detection result, err: %v", err)
+		}
+	}
+}
+
+func (inspector *Inspector) aggregateResults(results []map[LinkKey]SpeedCamResults, start time.Time,
 	inspectionDuration time.Duration) {
 
-	bandwidthPerNode := make(map[addr.IA]datasize.ByteSize)
+	bandwidthPerNode := make(map[addr.IA]float64)
 
 	for _, m := range results {
 		for _, v := range m {
 
 			for _, result := range v {
-				sourceBandwidth, exists := bandwidthPerNode[result.Source]
-				if !exists {
-					sourceBandwidth = 0
-				}
-				sourceBandwidth += datasize.ByteSize(uint64(result.BandwidthOut) / uint64(len(v)))
-				bandwidthPerNode[result.Source] = sourceBandwidth
-
-				targetBandwidth, exists := bandwidthPerNode[result.Neighbor]
-				if !exists {
-					targetBandwidth = 0
-				}
-				targetBandwidth += datasize.ByteSize(uint64(result.BandwidthIn) / uint64(len(v)))
-				bandwidthPerNode[result.Neighbor] = targetBandwidth
+				bandwidthPerNode[result.Source] += result.BandwidthOut / float64(len(v))
+				bandwidthPerNode[result.Neighbor] += result.BandwidthIn / float64(len(v))
 			}
 		}
 	}
 
-	for key, v := range bandwidthPerNode {
-		node, exists := inspector.graph.nodes[key]
-		if !exists {
+	for key, bandwidth := range bandwidthPerNode {
+		v := datasize.ByteSize(bandwidth)
+		if !inspector.graph.Contains(key) {
 			MyLogger.Warningf("Activity for node '%v' registered, but it was not in the graph. Node added!", key)
 			inspector.graph.AddIsdAs(key)
 
@@ -214,12 +359,36 @@ func (inspector *Inspector) aggregateResults(results []map[addr.IA][]SpeedCamRes
 					}
 				}
 			}
-			node = inspector.graph.nodes[key]
 		}
-		info := node.info
 		MyLogger.Debugf("Add activity to node '%v', start time: %v, duration: %v, average bytes/s: %v",
 			key, start, inspectionDuration, v.HR())
-		info.AddActivity(start, inspectionDuration, v)
+		err := inspector.graph.AddBandwidth(key, start, inspectionDuration, v)
+		if err != nil {
+			MyLogger.Errorf("error adding activity, err: %v", err)
+		}
+	}
+
+	// The results are grouped by their link
+	for _, m := range results {
+		for _, v := range m {
+			if len(v) == 0 {
+				continue
+			}
+			outgoing := 0.0
+			incoming := 0.0
+			for _, result := range v {
+				outgoing += result.BandwidthOut / float64(len(v))
+				incoming += result.BandwidthIn / float64(len(v))
+			}
+			link := v[0]
+			MyLogger.Debugf("Add activity to link '%v#%v<->%v', out: %v/s, in: %v/s", link.Source, link.IfId,
+				link.Neighbor, datasize.ByteSize(outgoing).HR(), datasize.ByteSize(incoming).HR())
+			err := inspector.graph.AddLinkBandwidth(link.Source, link.IfId, link.BrId, link.Neighbor, start,
+				inspectionDuration, datasize.ByteSize(outgoing), datasize.ByteSize(incoming))
+			if err != nil {
+				MyLogger.Errorf("error adding link activity, err: %v", err)
+			}
+		}
 	}
 
 }
@@ -235,38 +404,90 @@ func groupBySource(clientInfos []PrometheusClientInfo) map[addr.IA][]PrometheusC
 	return result
 }
 
-func (inspector *Inspector) fetchPathRequests() error {
-
-	for {
+// Fetches the path requests every period until the context is cancelled.
+func (inspector *Inspector) fetchPathRequests(ctx context.Context) error {
+	period := time.Duration(inspector.config.FetchPathRequestInterval) * time.Second
+	return inspector.fetchPeriodically(ctx, FetchSourcePathRequests, period, func(ctx context.Context) error {
 
-		pathRequests, err := inspector.fetcher.FetchPathRequests()
+		pathRequests, err := inspector.fetcher.FetchPathRequests(ctx)
 
 		for _, v := range pathRequests {
-			inspector.HandlePathRequest(v)
+			if err := inspector.HandlePathRequest(v); err != nil {
+				MyLogger.Warningf("Ignored path request, err: %v\n", err)
+			}
 		}
 		if err != nil {
-			MyLogger.Criticalf("error polling path requests, err: %v\n", err)
 			return err
 		}
 		MyLogger.Debugf("Handled %v path requests\n", len(pathRequests))
-		time.Sleep(5 * time.Minute)
-	}
-
-	return nil
+		return nil
+	})
 }
 
-func (inspector *Inspector) fetchBrInfo() error {
-
-	for {
-
-		err := inspector.brInfoFetcher.PollData()
+// Fetches the BR information every period until the context is cancelled.
+func (inspector *Inspector) fetchBrInfo(ctx context.Context) error {
+	period := time.Duration(inspector.config.FetchBrInfoInterval) * time.Second
+	return inspector.fetchPeriodically(ctx, FetchSourceBrInfo, period, func(ctx context.Context) error {
 
+		err := inspector.brInfoFetcher.PollData(ctx)
 		if err != nil {
-			MyLogger.Criticalf("error polling border router information, err: %v\n", err)
 			return err
 		}
 		MyLogger.Debugf("Polled %v border router information\n", len(inspector.brInfoFetcher.Info))
-		time.Sleep(5 * time.Minute)
+		inspector.setBrInfo(inspector.brInfoFetcher.Info)
+		return nil
+	})
+}
+
+// The current BR information for the SpeedCams. Pushed information replaces the fetched one about the same interface
+// of an AS.
+func (inspector *Inspector) BrInfo() []PrometheusClientInfo {
+	inspector.brInfoLock.RLock()
+	defer inspector.brInfoLock.RUnlock()
+
+	return replaceBrInfo(inspector.brInfo, inspector.pushedBrInfo)
+}
+
+// Replaces the fetched BR information and marks their links as seen. The pushed information is kept. The infos are
+// copied, the fetcher decodes the next poll into the same slice.
+func (inspector *Inspector) setBrInfo(infos []PrometheusClientInfo) {
+	inspector.brInfoLock.Lock()
+	inspector.brInfo = append([]PrometheusClientInfo(nil), infos...)
+	inspector.brInfoLock.Unlock()
+
+	inspector.markBrInfoSeen(infos)
+}
+
+// Adds the pushed BR information. It replaces the pushed information about the same interface of an AS.
+func (inspector *Inspector) AddBrInfo(infos []PrometheusClientInfo) {
+	inspector.brInfoLock.Lock()
+	inspector.pushedBrInfo = replaceBrInfo(inspector.pushedBrInfo, infos)
+	inspector.brInfoLock.Unlock()
+
+	inspector.markBrInfoSeen(infos)
+}
+
+// Appends the replacements to the information, which is not about the same interface of an AS as a replacement.
+func replaceBrInfo(infos []PrometheusClientInfo, replacements []PrometheusClientInfo) []PrometheusClientInfo {
+	result := make([]PrometheusClientInfo, 0, len(infos)+len(replacements))
+	for _, v := range infos {
+		replaced := false
+		for _, replacement := range replacements {
+			if v.SourceIsdAs == replacement.SourceIsdAs && v.IfId == replacement.IfId {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, v)
+		}
+	}
+	return append(result, replacements...)
+}
+
+func (inspector *Inspector) markBrInfoSeen(infos []PrometheusClientInfo) {
+	now := time.Now()
+	for _, info := range infos {
+		inspector.graph.MarkLinkSeen(info.SourceIsdAs, info.IfId, info.TargetIsdAs, 0, now)
 	}
-	return nil
 }
@@ -0,0 +1,271 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Version of the checkpoint format written by this build. Increase it when the format changes and migrate older
+// checkpoints in migrateState.
+const StateVersion = 1
+
+// Checkpoint of the graph and the episode history of an inspector. Capacities are not part of it, they are loaded
+// from the config again.
+type InspectorState struct {
+	Version int
+	Created time.Time
+	Nodes   []InspectorStateNode
+	// Every connection of two ASes has at least one link
+	Links []InspectorStateLink
+	// Counted path requests for the path centrality
+	PathRequests float64
+}
+
+type InspectorStateNode struct {
+	IsdAs    addr.IA
+	LastSeen time.Time
+	// Detection results of the previous episodes, latest first
+	Successes []bool
+	// Activities of the previous episodes, latest first
+	Activities []InspectionResultActivity
+	// Counted path requests whose path contains the AS
+	PathRequests float64
+}
+
+type InspectorStateLink struct {
+	Source     addr.IA
+	Target     addr.IA
+	SourceIfId int
+	TargetIfId int
+	SourceBrId string
+	TargetBrId string
+	// The relation of the target to the source
+	Type     LinkType
+	LastSeen time.Time
+	// Activities of the previous episodes, latest first
+	Activities []InspectionResultLinkActivity
+}
+
+// Creates an inspector with the graph restored from the state file of the config. Without a state file or if it
+// does not exist yet, the graph is empty.
+func CreateFromState(config *SpeedCamConfig) (*Inspector, error) {
+	if len(config.StateFile) == 0 {
+		return CreateEmptyGraph(config), nil
+	}
+	state, err := LoadStateFile(config.StateFile)
+	if os.IsNotExist(err) {
+		MyLogger.Infof("No state file '%v' yet, starting with an empty graph", config.StateFile)
+		return CreateEmptyGraph(config), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	graph, err := RestoreGraph(state, config)
+	if err != nil {
+		return nil, err
+	}
+	MyLogger.Infof("Restored %v ASes and %v links from '%v' of %v", len(state.Nodes), len(state.Links),
+		config.StateFile, state.Created)
+	return CreateWithGraph(config, graph), nil
+}
+
+// Writes the current state to the state file of the config. Does nothing without a state file.
+func (inspector *Inspector) Checkpoint() error {
+	if len(inspector.config.StateFile) == 0 {
+		return nil
+	}
+	err := WriteStateFile(inspector.config.StateFile, inspector.graph.State())
+	if err != nil {
+		return err
+	}
+	MyLogger.Debugf("Wrote checkpoint to '%v'", inspector.config.StateFile)
+	return nil
+}
+
+// Writes a checkpoint every state interval till the context is cancelled.
+func (inspector *Inspector) checkpointPeriodically(ctx context.Context) {
+	interval := time.Duration(inspector.config.StateInterval) * time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		err := inspector.Checkpoint()
+		if err != nil {
+			MyLogger.Errorf("error writing checkpoint, err: %v", err)
+		}
+	}
+}
+
+// The current state of the graph in the checkpoint format.
+func (graph *NetworkGraph) State() *InspectorState {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	state := &InspectorState{Version: StateVersion, Created: time.Now(), PathRequests: graph.pathTotal}
+	isdAses := make([]addr.IA, 0, len(graph.nodes))
+	for k := range graph.nodes {
+		isdAses = append(isdAses, k)
+	}
+	sortIsdAses(isdAses)
+	for _, isdAs := range isdAses {
+		info := graph.nodes[isdAs].info
+		node := InspectorStateNode{IsdAs: isdAs, LastSeen: graph.seen[isdAs], PathRequests: graph.pathCounts[isdAs]}
+		info.successes.Do(func(x interface{}) {
+			node.Successes = append(node.Successes, x.(bool))
+		})
+		info.activities.Do(func(x interface{}) {
+			if x == nil {
+				return
+			}
+			act := x.(activity)
+			node.Activities = append(node.Activities,
+				InspectionResultActivity{Start: act.start, Duration: act.duration, Bandwidth: act.bandwidth})
+		})
+		state.Nodes = append(state.Nodes, node)
+	}
+
+	keys := make([]LinkKey, 0, len(graph.links))
+	for k := range graph.links {
+		keys = append(keys, k)
+	}
+	sortLinkKeys(keys)
+	for _, k := range keys {
+		link := graph.links[k]
+		stateLink := InspectorStateLink{Source: k.A, Target: k.B, SourceIfId: k.IfIdA, TargetIfId: k.IfIdB,
+			SourceBrId: link.brIdA, TargetBrId: link.brIdB, Type: link.linkType, LastSeen: link.lastSeen}
+		link.activities.Do(func(x interface{}) {
+			if x == nil {
+				return
+			}
+			act := x.(linkActivity)
+			stateLink.Activities = append(stateLink.Activities, InspectionResultLinkActivity{Start: act.start,
+				Duration: act.duration, SourceToTarget: act.bandwidthAB, TargetToSource: act.bandwidthBA})
+		})
+		state.Links = append(state.Links, stateLink)
+	}
+	return state
+}
+
+// Creates a graph from a checkpoint. With less episodes in the config than in the checkpoint, the oldest episodes
+// are dropped.
+func RestoreGraph(state *InspectorState, config *SpeedCamConfig) (*NetworkGraph, error) {
+	err := migrateState(state)
+	if err != nil {
+		return nil, err
+	}
+
+	// The downtime since the checkpoint does not count as not seen
+	downtime := time.Since(state.Created)
+	if downtime < 0 {
+		downtime = 0
+	}
+
+	graph := CreateEmpty(config)
+	for _, v := range state.Nodes {
+		err := graph.AddIsdAs(v.IsdAs)
+		if err != nil {
+			return nil, err
+		}
+		info := graph.nodes[v.IsdAs].info
+		// Oldest first, so the latest episode ends up as the current one
+		for i := len(v.Successes) - 1; i >= 0; i-- {
+			info.AddDetectionResult(v.Successes[i])
+		}
+		for i := len(v.Activities) - 1; i >= 0; i-- {
+			act := v.Activities[i]
+			info.AddActivity(act.Start, act.Duration, act.Bandwidth)
+		}
+		graph.seen[v.IsdAs] = v.LastSeen.Add(downtime)
+		if v.PathRequests > 0 {
+			graph.pathCounts[v.IsdAs] = v.PathRequests
+		}
+	}
+	graph.pathTotal = state.PathRequests
+
+	for _, v := range state.Links {
+		err := graph.ConnectInterfaces(v.Source, v.SourceIfId, v.Target, v.TargetIfId)
+		if err != nil {
+			return nil, err
+		}
+		link := graph.link(NewLinkKey(v.Source, v.SourceIfId, v.Target, v.TargetIfId))
+		link.setBrId(v.Source, v.SourceBrId)
+		link.setBrId(v.Target, v.TargetBrId)
+		if v.Source == link.key.A {
+			link.linkType = v.Type
+		} else {
+			link.linkType = v.Type.reverse()
+		}
+		for i := len(v.Activities) - 1; i >= 0; i-- {
+			act := v.Activities[i]
+			if v.Source == link.key.A {
+				link.addActivity(act.Start, act.Duration, act.SourceToTarget, act.TargetToSource)
+			} else {
+				link.addActivity(act.Start, act.Duration, act.TargetToSource, act.SourceToTarget)
+			}
+		}
+		link.lastSeen = v.LastSeen.Add(downtime)
+	}
+	return graph, nil
+}
+
+// Converts a checkpoint of an older version to the current one.
+func migrateState(state *InspectorState) error {
+	switch state.Version {
+	case StateVersion:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("Unsupported state version %v, supported is up to %v", state.Version,
+			StateVersion))
+	}
+}
+
+// Loads a checkpoint from a JSON file.
+func LoadStateFile(filePath string) (*InspectorState, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var state InspectorState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid state file '%v', err: %v", filePath, err))
+	}
+	return &state, nil
+}
+
+// Writes a checkpoint as JSON file. The file is replaced at once, so a crash while writing keeps the previous one.
+func WriteStateFile(filePath string, state *InspectorState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmpPath := filePath + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
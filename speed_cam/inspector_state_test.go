@@ -0,0 +1,101 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	as112, _ := addr.IAFromString("1-12")
+	config := Default()
+	graph := Load(map[addr.IA][]addr.IA{as110: {as111}, as111: {as112}, as112: {}}, config)
+
+	start := time.Date(2018, 02, 23, 10, 0, 0, 0, time.UTC)
+	graph.AddDetectionResult(as111, true)
+	graph.AddDetectionResult(as111, false)
+	graph.AddBandwidth(as111, start, time.Minute, 3*datasize.MB)
+	graph.AddBandwidth(as111, start.Add(time.Hour), time.Minute, 5*datasize.MB)
+	graph.AddLinkBandwidth(as111, 2, "br1-11-1", as110, start, time.Minute, 2*datasize.MB, datasize.MB)
+	graph.ConnectInterfaces(as110, 18, as111, 3)
+
+	dir, err := ioutil.TempDir("", "speed_cam_state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := path.Join(dir, "state.json")
+
+	expected := graph.State()
+	err = WriteStateFile(filePath, expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := LoadStateFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreGraph(state, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := restored.State()
+	if len(actual.Nodes) != 3 || len(actual.Links) != 3 {
+		t.Fatalf("Expected 3 ASes and 3 links, but was %v", actual)
+	}
+	for i := range expected.Nodes {
+		actual.Nodes[i].LastSeen = expected.Nodes[i].LastSeen
+	}
+	for i := range expected.Links {
+		actual.Links[i].LastSeen = expected.Links[i].LastSeen
+	}
+	if !reflect.DeepEqual(expected.Nodes, actual.Nodes) {
+		t.Errorf("Expected ASes %v, but was %v", expected.Nodes, actual.Nodes)
+	}
+	if !reflect.DeepEqual(expected.Links, actual.Links) {
+		t.Errorf("Expected links %v, but was %v", expected.Links, actual.Links)
+	}
+	if degree := restored.nodes[as111].info.degree; degree != 2 {
+		t.Errorf("Expected degree 2 of 1-11, but was %v", degree)
+	}
+}
+
+func TestRestoreUnsupportedVersion(t *testing.T) {
+	state := &InspectorState{Version: StateVersion + 1, Created: time.Now()}
+	if _, err := RestoreGraph(state, Default()); err == nil {
+		t.Error("Expected an error for a newer state version")
+	}
+}
+
+func TestCreateFromMissingState(t *testing.T) {
+	config := Default()
+	config.StateFile = path.Join(os.TempDir(), "speed_cam_state_not_existing.json")
+
+	inspector, err := CreateFromState(config)
+	if err != nil || inspector.graph.Size() != 0 {
+		t.Errorf("Expected an empty graph without state file, but was %v (err: %v)", inspector, err)
+	}
+}
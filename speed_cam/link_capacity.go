@@ -0,0 +1,150 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"encoding/json"
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io/ioutil"
+)
+
+// The bandwidth of the SCION topology files is given in Mbit/s
+const topologyBandwidthUnit = datasize.ByteSize(1000 * 1000 / 8)
+
+// The capacity of a link from the interface of an AS to its neighbor.
+// Example entry of a capacity file: {"Source": "1-10", "Target": "1-11", "IfId": 16, "Capacity": "100MB"}
+type LinkCapacity struct {
+	Source addr.IA
+	Target addr.IA
+	// The interface of the source AS. Zero if unknown
+	IfId int
+	// Bytes per second
+	Capacity datasize.ByteSize
+}
+
+type linkCapacityKey struct {
+	target addr.IA
+	ifId   int
+}
+
+// Loads the link capacities of a JSON file containing a list of link capacities.
+func LoadCapacityFile(file string) ([]LinkCapacity, error) {
+	var capacities []LinkCapacity
+
+	readBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return capacities, err
+	}
+
+	err = json.Unmarshal(readBytes, &capacities)
+	return capacities, err
+}
+
+// Loads the link capacities from the interface bandwidths of all topology.json files in the directory, for example
+// the gen directory of SCION.
+func LoadTopologyCapacities(dir string) ([]LinkCapacity, error) {
+	interfaces, err := LoadTopologyDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return TopologyCapacities(interfaces), nil
+}
+
+// Adds capacities of links to the graph. The links and ASes do not have to be part of the graph yet.
+func (graph *NetworkGraph) AddCapacities(capacities []LinkCapacity) {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	for _, v := range capacities {
+		interfaces, exists := graph.capacities[v.Source]
+		if !exists {
+			interfaces = make(map[linkCapacityKey]datasize.ByteSize)
+			graph.capacities[v.Source] = interfaces
+		}
+		interfaces[linkCapacityKey{target: v.Target, ifId: v.IfId}] = v.Capacity
+	}
+
+	for _, v := range capacities {
+		graph.updateCapacity(v.Source)
+		graph.updateCapacity(v.Target)
+	}
+	graph.updateLinkCapacities()
+}
+
+// The capacity of all links between the two ASes. Links known only by the other side are assumed to be symmetric.
+func (graph *NetworkGraph) LinkCapacity(source addr.IA, target addr.IA) datasize.ByteSize {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	return graph.linkCapacity(source, target)
+}
+
+// The capacity of the link from the interface of the source to the target. With an unknown interface it is the
+// capacity of all links between both ASes.
+func (graph *NetworkGraph) InterfaceCapacity(source addr.IA, ifId int, target addr.IA) datasize.ByteSize {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	return graph.interfaceCapacity(NewLinkKey(source, ifId, target, 0))
+}
+
+func (graph *NetworkGraph) linkCapacity(source addr.IA, target addr.IA) datasize.ByteSize {
+	capacity := graph.directedLinkCapacity(source, target)
+	if capacity == 0 {
+		capacity = graph.directedLinkCapacity(target, source)
+	}
+	return capacity
+}
+
+func (graph *NetworkGraph) directedLinkCapacity(source addr.IA, target addr.IA) datasize.ByteSize {
+	var capacity datasize.ByteSize
+	for k, v := range graph.capacities[source] {
+		if k.target == target {
+			capacity += v
+		}
+	}
+	return capacity
+}
+
+// The capacity of an AS is the sum of the capacities of its links.
+func (graph *NetworkGraph) nodeCapacity(isdAs addr.IA) datasize.ByteSize {
+	targets := make(map[addr.IA]bool)
+	for k := range graph.capacities[isdAs] {
+		targets[k.target] = true
+	}
+	// Links only known by the neighbor
+	for source, interfaces := range graph.capacities {
+		for k := range interfaces {
+			if k.target == isdAs {
+				targets[source] = true
+			}
+		}
+	}
+
+	var capacity datasize.ByteSize
+	for target := range targets {
+		capacity += graph.linkCapacity(isdAs, target)
+	}
+	return capacity
+}
+
+func (graph *NetworkGraph) updateCapacity(isdAs addr.IA) {
+	node, exists := graph.nodes[isdAs]
+	if exists {
+		node.info.capacity = graph.nodeCapacity(isdAs)
+	}
+}
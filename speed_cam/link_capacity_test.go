@@ -0,0 +1,113 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+const testTopology = `{
+  "ISD_AS": "1-10",
+  "BorderRouters": {
+    "br1-10-1": {
+      "Interfaces": {
+        "16": {"ISD_AS": "1-11", "Bandwidth": 1000, "LinkTo": "PARENT"}
+      }
+    },
+    "br1-10-2": {
+      "Interfaces": {
+        "17": {"ISD_AS": "1-12", "Bandwidth": 8, "LinkTo": "CHILD"}
+      }
+    }
+  }
+}`
+
+func TestLoadTopologyCapacities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "speedcam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	brDir := path.Join(dir, "ISD1", "AS10", "br1-10-1")
+	os.MkdirAll(brDir, 0777)
+	ioutil.WriteFile(path.Join(brDir, "topology.json"), []byte(testTopology), 0666)
+
+	capacities, err := LoadTopologyCapacities(dir)
+	if err != nil {
+		t.Fatalf("error loading topology: %v", err)
+	}
+	if len(capacities) != 2 {
+		t.Fatalf("Expected 2 capacities, but was %v", capacities)
+	}
+
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	as112, _ := addr.IAFromString("1-12")
+	graph := Load(map[addr.IA][]addr.IA{as110: {as111, as112}, as111: {}, as112: {}}, Default())
+	graph.AddCapacities(capacities)
+
+	// 1000 Mbit/s and 8 Mbit/s
+	if capacity := graph.LinkCapacity(as111, as110); capacity != datasize.ByteSize(125000000) {
+		t.Errorf("Expected capacity of 125000000 B/s, but was %v", uint64(capacity))
+	}
+	expected := datasize.ByteSize(126000000)
+	if capacity := graph.nodes[as110].info.capacity; capacity != expected {
+		t.Errorf("Expected capacity of 1-10 %v B/s, but was %v", uint64(expected), uint64(capacity))
+	}
+	expected = datasize.ByteSize(1000000)
+	if capacity := graph.nodes[as112].info.capacity; capacity != expected {
+		t.Errorf("Expected capacity of 1-12 %v B/s, but was %v", uint64(expected), uint64(capacity))
+	}
+}
+
+func TestLoadCapacityFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "capacities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`[{"Source": "1-10", "Target": "1-11", "IfId": 16, "Capacity": "10MB"},
+		{"Source": "1-10", "Target": "1-11", "IfId": 18, "Capacity": "5MB"}]`)
+	file.Close()
+
+	capacities, err := LoadCapacityFile(file.Name())
+	if err != nil {
+		t.Fatalf("error loading capacity file: %v", err)
+	}
+
+	// The capacities are known before the ASes are
+	graph := CreateEmpty(Default())
+	graph.AddCapacities(capacities)
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	graph.AddIsdAs(as110)
+	graph.AddIsdAs(as111)
+
+	// Parallel links are summed up
+	expected := 15 * datasize.MB
+	if capacity := graph.nodes[as110].info.capacity; capacity != expected {
+		t.Errorf("Expected capacity of 1-10 %v, but was %v", expected, capacity)
+	}
+	if capacity := graph.nodes[as111].info.capacity; capacity != expected {
+		t.Errorf("Expected capacity of 1-11 %v, but was %v", expected, capacity)
+	}
+}
@@ -20,14 +20,35 @@ import (
 	"fmt"
 	"github.com/c2h5oh/datasize"
 	"github.com/scionproto/scion/go/lib/addr"
+	"sync"
 	"time"
 )
 
-// A symmetric graph representing the topology of a SCION network
+// A symmetric graph representing the topology of a SCION network. It is safe for concurrent use, readers should
+// work on a Snapshot.
 type NetworkGraph struct {
+	lock   sync.RWMutex
 	nodes  map[addr.IA]networkNode
 	size   uint32
 	config *SpeedCamConfig
+	// Known link capacities per AS and its interfaces, independent of the ASes being part of the graph
+	capacities map[addr.IA]map[linkCapacityKey]datasize.ByteSize
+	// The links between the connected ASes. Every connection has at least one link
+	links map[LinkKey]*networkLink
+	// When each AS was seen the last time
+	seen map[addr.IA]time.Time
+	// ASes of the topology files, which never expire
+	static map[addr.IA]bool
+	// Called for every AS and link removed by Expire
+	removalListeners []func(event RemovalEvent)
+	// Counted path requests per AS on their path and in total
+	pathCounts map[addr.IA]float64
+	pathTotal  float64
+	// Increased whenever ASes are connected or removed
+	structureVersion uint64
+	// Guards the cached betweenness, which is calculated by readers on their snapshots
+	centralityLock   sync.Mutex
+	betweennessCache *betweennessState
 }
 
 // Creates an empty graph without any ASes inside
@@ -36,6 +57,11 @@ func CreateEmpty(config *SpeedCamConfig) *NetworkGraph {
 	graph.nodes = make(map[addr.IA]networkNode)
 	graph.size = 0
 	graph.config = config
+	graph.capacities = make(map[addr.IA]map[linkCapacityKey]datasize.ByteSize)
+	graph.links = make(map[LinkKey]*networkLink)
+	graph.seen = make(map[addr.IA]time.Time)
+	graph.static = make(map[addr.IA]bool)
+	graph.pathCounts = make(map[addr.IA]float64)
 	return graph
 }
 
@@ -43,9 +69,11 @@ func CreateEmpty(config *SpeedCamConfig) *NetworkGraph {
 func Load(connections map[addr.IA][]addr.IA, config *SpeedCamConfig) *NetworkGraph {
 	graph := CreateEmpty(config)
 
-	for k, neighbors := range connections {
+	// All ASes must exist before connecting them
+	for k := range connections {
 		graph.AddIsdAs(k)
-
+	}
+	for k, neighbors := range connections {
 		for _, neighbor := range neighbors {
 			graph.ConnectIsdAses(k, neighbor)
 		}
@@ -57,6 +85,13 @@ func Load(connections map[addr.IA][]addr.IA, config *SpeedCamConfig) *NetworkGra
 // Adds an AS to the graph without connections or information about it.
 // Duplicate ISD-ASes are permitted and will result in an error.
 func (graph *NetworkGraph) AddIsdAs(isdAs addr.IA) error {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	return graph.addIsdAs(isdAs)
+}
+
+func (graph *NetworkGraph) addIsdAs(isdAs addr.IA) error {
 	_, exists := graph.nodes[isdAs]
 	// Do not add an existing AS twice
 	if exists {
@@ -65,16 +100,31 @@ func (graph *NetworkGraph) AddIsdAs(isdAs addr.IA) error {
 	node := new(networkNode)
 	node.IsdAs = isdAs
 	node.info = NewInfo(isdAs, graph.config)
+	node.info.capacity = graph.nodeCapacity(isdAs)
 	node.neighbors = make(map[addr.IA]networkNode)
 	graph.nodes[isdAs] = *node
+	graph.seen[isdAs] = time.Now()
 	graph.size++
+	graph.structureVersion++
 	return nil
 }
 
 // Connects two ASes with each other and increases their degrees by one.
 // The both ASes must be added to the graph or the call will result in an error, so will already connected ASes.
 func (graph *NetworkGraph) ConnectIsdAses(source addr.IA, target addr.IA) error {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	err := graph.connect(source, target)
+	if err != nil {
+		return err
+	}
+	// The interfaces are unknown
+	graph.link(NewLinkKey(source, 0, target, 0))
+	return nil
+}
 
+func (graph *NetworkGraph) connect(source addr.IA, target addr.IA) error {
 	sourceNode, exists := graph.nodes[source]
 	if !exists {
 		return errors.New(fmt.Sprintf("Source %v not existing in graph", source))
@@ -97,13 +147,22 @@ func (graph *NetworkGraph) ConnectIsdAses(source addr.IA, target addr.IA) error
 	targetNode.neighbors[source] = sourceNode
 	sourceNode.info.degree += 1
 	targetNode.info.degree += 1
+	graph.structureVersion++
 
 	return nil
 }
 
-func (graph *NetworkGraph) AddBandwidth(isdAs *addr.IA, start time.Time, duration time.Duration, bandwidth datasize.ByteSize) error {
+func (graph *NetworkGraph) connected(source addr.IA, target addr.IA) bool {
+	_, exists := graph.nodes[source].neighbors[target]
+	return exists
+}
+
+// Adds the measured bandwidth of an episode to the history of the AS.
+func (graph *NetworkGraph) AddBandwidth(isdAs addr.IA, start time.Time, duration time.Duration, bandwidth datasize.ByteSize) error {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
 
-	node, exists := graph.nodes[*isdAs]
+	node, exists := graph.nodes[isdAs]
 	if !exists {
 		return errors.New(fmt.Sprintf("AS %v not added to graph!", isdAs))
 	}
@@ -113,6 +172,89 @@ func (graph *NetworkGraph) AddBandwidth(isdAs *addr.IA, start time.Time, duratio
 	return nil
 }
 
+// Adds the result of a SpeedCam on the AS to its history.
+func (graph *NetworkGraph) AddDetectionResult(isdAs addr.IA, isSuccess bool) error {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	node, exists := graph.nodes[isdAs]
+	if !exists {
+		return errors.New(fmt.Sprintf("AS %v not added to graph!", isdAs))
+	}
+
+	node.info.AddDetectionResult(isSuccess)
+
+	return nil
+}
+
+// The amount of ASes in the graph.
+func (graph *NetworkGraph) Size() uint32 {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	return graph.size
+}
+
+// Checks if the AS is part of the graph.
+func (graph *NetworkGraph) Contains(isdAs addr.IA) bool {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	_, exists := graph.nodes[isdAs]
+	return exists
+}
+
+// Copies the current state of the graph. Later changes of the graph do not affect the snapshot.
+func (graph *NetworkGraph) Snapshot() *GraphSnapshot {
+	graph.lock.RLock()
+	snapshot := &GraphSnapshot{size: graph.size, nodes: make(map[addr.IA]networkNode, len(graph.nodes)),
+		links: make(map[LinkKey]*networkLink, len(graph.links))}
+	version := graph.structureVersion
+	for k, v := range graph.nodes {
+		info := v.info.copy()
+		info.pathCentrality = graph.pathCentrality(k)
+		snapshot.nodes[k] = networkNode{IsdAs: k, info: info, neighbors: make(map[addr.IA]networkNode)}
+	}
+	// The neighbors refer to the copied nodes
+	for k, v := range graph.nodes {
+		for neighbor := range v.neighbors {
+			snapshot.nodes[k].neighbors[neighbor] = snapshot.nodes[neighbor]
+		}
+	}
+	for k, v := range graph.links {
+		snapshot.links[k] = v.copy()
+	}
+	graph.lock.RUnlock()
+
+	betweenness := graph.betweenness(snapshot.nodes, version)
+	for k, v := range snapshot.nodes {
+		v.info.betweenness = betweenness[k]
+	}
+	return snapshot
+}
+
+// An immutable copy of a NetworkGraph.
+type GraphSnapshot struct {
+	nodes map[addr.IA]networkNode
+	links map[LinkKey]*networkLink
+	size  uint32
+}
+
+// The amount of ASes in the snapshot.
+func (snapshot *GraphSnapshot) Size() uint32 {
+	return snapshot.size
+}
+
+// The neighbors of the AS in the snapshot, sorted by ISD and AS. Empty if the AS is unknown.
+func (snapshot *GraphSnapshot) Neighbors(isdAs addr.IA) []addr.IA {
+	var neighbors []addr.IA
+	for k := range snapshot.nodes[isdAs].neighbors {
+		neighbors = append(neighbors, k)
+	}
+	sortIsdAses(neighbors)
+	return neighbors
+}
+
 type networkNode struct {
 	IsdAs     addr.IA
 	info      *speedCamInfo
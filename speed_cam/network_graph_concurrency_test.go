@@ -0,0 +1,128 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"fmt"
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The tests of this file are meant to be run with the race detector: go test -race
+
+func TestSnapshotImmutable(t *testing.T) {
+	as17, _ := addr.IAFromString("1-7")
+	as18, _ := addr.IAFromString("1-8")
+	as19, _ := addr.IAFromString("1-9")
+	graph := Load(map[addr.IA][]addr.IA{as17: {as18}, as18: {}}, Default())
+
+	snapshot := graph.Snapshot()
+
+	graph.AddIsdAs(as19)
+	graph.ConnectIsdAses(as18, as19)
+	graph.AddBandwidth(as17, time.Now(), time.Minute, datasize.MB)
+	graph.AddDetectionResult(as17, true)
+
+	if snapshot.Size() != 2 || len(snapshot.nodes) != 2 {
+		t.Errorf("Expected 2 nodes in the snapshot, but was %v", snapshot.Size())
+	}
+	if neighbors := snapshot.Neighbors(as18); len(neighbors) != 1 || neighbors[0] != as17 {
+		t.Errorf("Expected 1-7 as only neighbor of 1-8, but was %v", neighbors)
+	}
+	if snapshot.nodes[as18].info.degree != 1 {
+		t.Errorf("Expected degree 1 of 1-8, but was %v", snapshot.nodes[as18].info.degree)
+	}
+	info := snapshot.nodes[as17].info
+	if _, exists := info.AverageBandwidth(); exists || info.SuccessRate() != 0 {
+		t.Error("Expected no history of 1-7 in the snapshot")
+	}
+
+	// The graph itself changed
+	if graph.Size() != 3 || graph.nodes[as17].info.SuccessRate() != 1 {
+		t.Errorf("Expected the changes in the graph, size: %v", graph.Size())
+	}
+}
+
+func TestSnapshotNeighbors(t *testing.T) {
+	as17, _ := addr.IAFromString("1-7")
+	as18, _ := addr.IAFromString("1-8")
+	as29, _ := addr.IAFromString("2-9")
+	graph := Load(map[addr.IA][]addr.IA{as29: {as17, as18}, as17: {}, as18: {}}, Default())
+
+	snapshot := graph.Snapshot()
+	neighbors := snapshot.Neighbors(as29)
+	if len(neighbors) != 2 || neighbors[0] != as17 || neighbors[1] != as18 {
+		t.Errorf("Expected sorted neighbors [1-7 1-8], but was %v", neighbors)
+	}
+	// The neighbors are nodes of the snapshot
+	if snapshot.nodes[as29].neighbors[as17].info != snapshot.nodes[as17].info {
+		t.Error("Expected the neighbor to be the node of the snapshot")
+	}
+}
+
+// Path requests change the graph while an inspection reads and updates it
+func TestConcurrentGraphAccess(t *testing.T) {
+	config := Default()
+	config.IntervalStrategy = "experience"
+	inspector := CreateEmptyGraph(config)
+	source, _ := addr.IAFromString("1-1")
+	inspector.graph.AddIsdAs(source)
+
+	var wait sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			for j := 2; j < 50; j++ {
+				inspector.HandlePathRequest(fmt.Sprintf("1-1 1>1 %v-%v", i+1, j))
+			}
+		}(i)
+	}
+
+	for i := 0; i < 4; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for j := 0; j < 20; j++ {
+				snapshot := inspector.graph.Snapshot()
+				selector := Create(config)
+				for _, node := range snapshot.nodes {
+					selector.calculateScore(node)
+				}
+
+				neighbor, _ := addr.IAFromString("1-2")
+				results := []map[LinkKey]SpeedCamResults{{NewLinkKey(source, 0, neighbor, 0): {{Source: source,
+					Neighbor: neighbor, BandwidthIn: 1000, BandwidthOut: 2000}}}}
+				inspector.detectCongestions([]networkNode{snapshot.nodes[source]}, results)
+				inspector.aggregateResults(results, time.Now(), time.Minute)
+				SerializableResult(inspector, results, nil, time.Now(), time.Minute)
+				getWaitTime(inspector)
+			}
+		}()
+	}
+	wait.Wait()
+
+	// 1-1, its neighbors 1-2 .. 1-49 and 2-2 .. 4-49
+	if size := inspector.graph.Size(); size != 1+4*48 {
+		t.Errorf("Expected %v ASes, but was %v", 1+4*48, size)
+	}
+	if degree := inspector.graph.nodes[source].info.degree; degree != 4*48 {
+		t.Errorf("Expected degree %v of 1-1, but was %v", 4*48, degree)
+	}
+}
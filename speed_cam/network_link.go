@@ -0,0 +1,304 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"container/ring"
+	"errors"
+	"fmt"
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identifies a link by the ASes and their interfaces on both ends. Both directions of a link have the same key, the
+// smaller ISD-AS is always A. An interface id of zero is unknown.
+type LinkKey struct {
+	A     addr.IA
+	B     addr.IA
+	IfIdA int
+	IfIdB int
+}
+
+// Creates the key of the link between the interface of the source and the interface of the target.
+func NewLinkKey(source addr.IA, sourceIfId int, target addr.IA, targetIfId int) LinkKey {
+	if isdAsLess(target, source) {
+		return LinkKey{A: target, B: source, IfIdA: targetIfId, IfIdB: sourceIfId}
+	}
+	return LinkKey{A: source, B: target, IfIdA: sourceIfId, IfIdB: targetIfId}
+}
+
+func (key LinkKey) String() string {
+	return fmt.Sprintf("%v#%v<->%v#%v", key.A, key.IfIdA, key.B, key.IfIdB)
+}
+
+// The key is written like String, e.g. as key of a JSON object.
+func (key LinkKey) MarshalText() ([]byte, error) {
+	return []byte(key.String()), nil
+}
+
+func (key *LinkKey) UnmarshalText(text []byte) error {
+	ends := strings.Split(string(text), "<->")
+	if len(ends) != 2 {
+		return errors.New(fmt.Sprintf("Invalid link key '%s'", text))
+	}
+	a, ifIdA, err := parseLinkEnd(ends[0])
+	if err != nil {
+		return err
+	}
+	b, ifIdB, err := parseLinkEnd(ends[1])
+	if err != nil {
+		return err
+	}
+	*key = LinkKey{A: a, B: b, IfIdA: ifIdA, IfIdB: ifIdB}
+	return nil
+}
+
+// Parses an end of a link key, e.g. '1-10#16'
+func parseLinkEnd(end string) (addr.IA, int, error) {
+	index := strings.LastIndex(end, "#")
+	if index < 0 {
+		return addr.IA{}, 0, errors.New(fmt.Sprintf("Invalid link end '%v'", end))
+	}
+	isdAs, err := addr.IAFromString(end[:index])
+	if err != nil {
+		return addr.IA{}, 0, err
+	}
+	ifId, err := strconv.Atoi(end[index+1:])
+	if err != nil {
+		return addr.IA{}, 0, err
+	}
+	return isdAs, ifId, nil
+}
+
+// Checks if both keys can describe the same link, because their known interfaces are equal.
+func (key LinkKey) matches(other LinkKey) bool {
+	return key.A == other.A && key.B == other.B && sameInterface(key.IfIdA, other.IfIdA) &&
+		sameInterface(key.IfIdB, other.IfIdB)
+}
+
+// Combines the known interfaces of both keys.
+func (key LinkKey) merge(other LinkKey) LinkKey {
+	if key.IfIdA == 0 {
+		key.IfIdA = other.IfIdA
+	}
+	if key.IfIdB == 0 {
+		key.IfIdB = other.IfIdB
+	}
+	return key
+}
+
+func sameInterface(ifId int, other int) bool {
+	return ifId == 0 || other == 0 || ifId == other
+}
+
+// A link between two ASes with its metadata and measured history.
+type networkLink struct {
+	key LinkKey
+	// Ids of the border routers on the ends of the link, empty if unknown
+	brIdA    string
+	brIdB    string
+	capacity datasize.ByteSize
+	// The relation of B to A, e.g. PARENT if B is the parent of A. Empty if unknown
+	linkType LinkType
+	// Last episodes of linkActivity, nil values for episodes without measurement
+	activities *ring.Ring
+	// When the link was seen the last time
+	lastSeen time.Time
+	// Links of the topology files never expire
+	static bool
+}
+
+// The measured bandwidth of a link in both directions
+type linkActivity struct {
+	start    time.Time
+	duration time.Duration
+	// Bytes per second from A to B and from B to A
+	bandwidthAB datasize.ByteSize
+	bandwidthBA datasize.ByteSize
+}
+
+func newLink(key LinkKey, config *SpeedCamConfig) *networkLink {
+	link := &networkLink{key: key, activities: ring.New(config.Episodes)}
+	for i := 0; i < config.Episodes; i++ {
+		link.activities.Value = nil
+		link.activities = link.activities.Next()
+	}
+	return link
+}
+
+func (link *networkLink) addActivity(start time.Time, duration time.Duration, bandwidthAB datasize.ByteSize,
+	bandwidthBA datasize.ByteSize) {
+	link.activities = link.activities.Prev()
+	link.activities.Value = linkActivity{start: start, duration: duration, bandwidthAB: bandwidthAB,
+		bandwidthBA: bandwidthBA}
+}
+
+func (link *networkLink) setBrId(isdAs addr.IA, brId string) {
+	if len(brId) == 0 {
+		return
+	}
+	if isdAs == link.key.A {
+		link.brIdA = brId
+	} else if isdAs == link.key.B {
+		link.brIdB = brId
+	}
+}
+
+func (link *networkLink) copy() *networkLink {
+	result := *link
+	result.activities = copyRing(link.activities)
+	return &result
+}
+
+// Connects the interfaces of two ASes. Unlike ConnectIsdAses, already connected ASes are no error, so parallel links
+// and more details about known links can be added. Both ASes must be part of the graph.
+func (graph *NetworkGraph) ConnectInterfaces(source addr.IA, sourceIfId int, target addr.IA, targetIfId int) error {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	if !graph.connected(source, target) {
+		err := graph.connect(source, target)
+		if err != nil {
+			return err
+		}
+	}
+	graph.link(NewLinkKey(source, sourceIfId, target, targetIfId))
+	return nil
+}
+
+// Adds the bandwidth measured by a SpeedCam on the source for its interface to the target. Outgoing is the bandwidth
+// from the source to the target.
+func (graph *NetworkGraph) AddLinkBandwidth(source addr.IA, sourceIfId int, brId string, target addr.IA,
+	start time.Time, duration time.Duration, outgoing datasize.ByteSize, incoming datasize.ByteSize) error {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	if !graph.connected(source, target) {
+		return errors.New(fmt.Sprintf("Link %v<->%v not added to graph!", source, target))
+	}
+
+	link := graph.link(NewLinkKey(source, sourceIfId, target, 0))
+	link.setBrId(source, brId)
+	if source == link.key.A {
+		link.addActivity(start, duration, outgoing, incoming)
+	} else {
+		link.addActivity(start, duration, incoming, outgoing)
+	}
+	return nil
+}
+
+// Finds the link matching the key or creates it. A found link learns the interfaces of the key it did not know yet.
+// If the unknown interfaces of the key match several parallel links, it is unclear which one is meant, so a separate
+// link is created for the key.
+func (graph *NetworkGraph) link(key LinkKey) *networkLink {
+	if link, exists := graph.links[key]; exists {
+		return link
+	}
+
+	var matches []LinkKey
+	for k := range graph.links {
+		if k.matches(key) {
+			matches = append(matches, k)
+		}
+	}
+	if len(matches) == 1 {
+		k := matches[0]
+		link := graph.links[k]
+		merged := k.merge(key)
+		if _, exists := graph.links[merged]; !exists {
+			delete(graph.links, k)
+			link.key = merged
+			link.capacity = graph.interfaceCapacity(merged)
+			graph.links[merged] = link
+		}
+		return link
+	}
+
+	link := newLink(key, graph.config)
+	link.capacity = graph.interfaceCapacity(key)
+	link.lastSeen = time.Now()
+	graph.links[key] = link
+	return link
+}
+
+// The capacity of the link. With unknown interfaces it is the capacity of all links between both ASes.
+func (graph *NetworkGraph) interfaceCapacity(key LinkKey) datasize.ByteSize {
+	if key.IfIdA != 0 {
+		if capacity, exists := graph.capacities[key.A][linkCapacityKey{target: key.B, ifId: key.IfIdA}]; exists {
+			return capacity
+		}
+	}
+	if key.IfIdB != 0 {
+		if capacity, exists := graph.capacities[key.B][linkCapacityKey{target: key.A, ifId: key.IfIdB}]; exists {
+			return capacity
+		}
+	}
+	return graph.linkCapacity(key.A, key.B)
+}
+
+func (graph *NetworkGraph) updateLinkCapacities() {
+	for k, link := range graph.links {
+		link.capacity = graph.interfaceCapacity(k)
+	}
+}
+
+// The links of the snapshot sorted by their ASes and interfaces.
+func (snapshot *GraphSnapshot) Links() []LinkKey {
+	keys := make([]LinkKey, 0, len(snapshot.links))
+	for k := range snapshot.links {
+		keys = append(keys, k)
+	}
+	sortLinkKeys(keys)
+	return keys
+}
+
+func sortLinkKeys(keys []LinkKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		return linkKeyLess(keys[i], keys[j])
+	})
+}
+
+// Orders link keys by their ASes and interfaces.
+func linkKeyLess(a LinkKey, b LinkKey) bool {
+	if a.A != b.A {
+		return isdAsLess(a.A, b.A)
+	}
+	if a.B != b.B {
+		return isdAsLess(a.B, b.B)
+	}
+	if a.IfIdA != b.IfIdA {
+		return a.IfIdA < b.IfIdA
+	}
+	return a.IfIdB < b.IfIdB
+}
+
+func sortIsdAses(isdAses []addr.IA) {
+	sort.Slice(isdAses, func(i, j int) bool {
+		return isdAsLess(isdAses[i], isdAses[j])
+	})
+}
+
+// Orders ISD-ASes by ISD and AS.
+func isdAsLess(a addr.IA, b addr.IA) bool {
+	if a.I != b.I {
+		return a.I < b.I
+	}
+	return a.A < b.A
+}
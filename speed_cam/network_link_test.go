@@ -0,0 +1,142 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+	"testing"
+	"time"
+)
+
+func TestNewLinkKey(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+
+	key := NewLinkKey(as111, 1, as110, 16)
+	if key != NewLinkKey(as110, 16, as111, 1) {
+		t.Errorf("Expected the same key for both directions, but was %v", key)
+	}
+	if key.A != as110 || key.IfIdA != 16 || key.B != as111 || key.IfIdB != 1 {
+		t.Errorf("Expected 1-10#16<->1-11#1, but was %v", key)
+	}
+}
+
+func TestLinkBandwidth(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	graph := Load(map[addr.IA][]addr.IA{as111: {as110}, as110: {}}, Default())
+	graph.AddCapacities([]LinkCapacity{
+		{Source: as110, Target: as111, IfId: 16, Capacity: 10 * datasize.MB},
+		{Source: as110, Target: as111, IfId: 18, Capacity: 5 * datasize.MB},
+	})
+
+	start := time.Date(2018, 02, 23, 10, 0, 0, 0, time.Local)
+	// The unknown link of the connection learns its interface
+	err := graph.AddLinkBandwidth(as110, 16, "br1-10-1", as111, start, time.Minute, 4*datasize.MB, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same link measured by the other AS
+	graph.AddLinkBandwidth(as111, 1, "br1-11-1", as110, start.Add(time.Hour), time.Minute, 2*datasize.MB, datasize.MB)
+	// A parallel link
+	graph.AddLinkBandwidth(as110, 18, "br1-10-2", as111, start, time.Minute, 3*datasize.MB, 0)
+
+	snapshot := graph.Snapshot()
+	links := snapshot.Links()
+	if len(links) != 2 {
+		t.Fatalf("Expected 2 links, but was %v", links)
+	}
+	expected := LinkKey{A: as110, B: as111, IfIdA: 16, IfIdB: 1}
+	if links[0] != expected {
+		t.Errorf("Expected link %v, but was %v", expected, links[0])
+	}
+
+	link := snapshot.links[expected]
+	if link.brIdA != "br1-10-1" || link.brIdB != "br1-11-1" || link.capacity != 10*datasize.MB {
+		t.Errorf("Unexpected link metadata %v", link)
+	}
+	var activities []linkActivity
+	link.activities.Do(func(x interface{}) {
+		if x != nil {
+			activities = append(activities, x.(linkActivity))
+		}
+	})
+	// Latest first, bandwidth from 1-10 to 1-11 and back
+	if len(activities) != 2 || activities[0].bandwidthAB != datasize.MB || activities[0].bandwidthBA != 2*datasize.MB ||
+		activities[1].bandwidthAB != 4*datasize.MB {
+		t.Errorf("Unexpected link activities %v", activities)
+	}
+
+	if capacity := snapshot.links[links[1]].capacity; capacity != 5*datasize.MB {
+		t.Errorf("Expected capacity of the parallel link of 5 MB, but was %v", capacity)
+	}
+}
+
+func TestLinkBandwidthNotConnected(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	graph := Load(map[addr.IA][]addr.IA{as110: {}, as111: {}}, Default())
+
+	err := graph.AddLinkBandwidth(as110, 16, "", as111, time.Now(), time.Minute, datasize.MB, datasize.MB)
+	if err == nil {
+		t.Error("Expected an error for not connected ASes")
+	}
+}
+
+// Bandwidth of an unknown interface cannot be assigned to one of several parallel links
+func TestLinkBandwidthParallelWildcard(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	graph := Load(map[addr.IA][]addr.IA{as110: {}, as111: {}}, Default())
+	graph.ConnectInterfaces(as110, 16, as111, 1)
+	graph.ConnectInterfaces(as110, 18, as111, 2)
+
+	start := time.Date(2018, 02, 23, 10, 0, 0, 0, time.Local)
+	for i := 0; i < 10; i++ {
+		graph.AddLinkBandwidth(as110, 0, "", as111, start, time.Minute, datasize.MB, 0)
+	}
+
+	snapshot := graph.Snapshot()
+	links := snapshot.Links()
+	if len(links) != 3 {
+		t.Fatalf("Expected 2 parallel links and 1 link of the unknown interface, but was %v", links)
+	}
+	for _, k := range links {
+		activities := 0
+		snapshot.links[k].activities.Do(func(x interface{}) {
+			if x != nil {
+				activities++
+			}
+		})
+		wildcard := k.IfIdA == 0 && k.IfIdB == 0
+		if (wildcard && activities != 6) || (!wildcard && activities != 0) {
+			t.Errorf("Unexpected %v activities of link %v", activities, k)
+		}
+	}
+}
+
+func TestLinkKeyText(t *testing.T) {
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	key := NewLinkKey(as110, 16, as111, 1)
+
+	text, _ := key.MarshalText()
+	var parsed LinkKey
+	if err := parsed.UnmarshalText(text); err != nil || parsed != key {
+		t.Errorf("Expected %v, but was %v (err: %v)", key, parsed, err)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Distribution of packet sizes of a link, taken from the pkt_size_bytes histograms of the BR.
+type PacketSizeHistogram struct {
+	// Upper bounds of the buckets in bytes, ascending. The last bucket has no upper bound (+Inf)
+	Bounds []float64
+	// Packets per bucket, not cumulative like Prometheus buckets. One more entry than bounds
+	Counts []float64
+	// Bytes of all packets
+	Sum float64
+}
+
+// Loads the histogram of the given name, e.g. border_input_pkt_size_bytes, for the samples with the labels. Empty if
+// the BR does not export it.
+func packetSizeHistogram(metrics PrometheusMetrics, name string, labels map[string]string) PacketSizeHistogram {
+	var histogram PacketSizeHistogram
+
+	type bucket struct {
+		bound float64
+		count float64
+	}
+	var buckets []bucket
+	for _, sample := range metrics.Samples(name+"_bucket", labels) {
+		bound, err := parsePrometheusFloat(sample.Labels["le"])
+		if err != nil {
+			MyLogger.Warningf("Invalid bucket bound '%v' of %v", sample.Labels["le"], name)
+			continue
+		}
+		buckets = append(buckets, bucket{bound: bound, count: sample.Value})
+	}
+	if len(buckets) == 0 {
+		return histogram
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].bound < buckets[j].bound
+	})
+
+	previous := 0.0
+	for _, v := range buckets {
+		if !math.IsInf(v.bound, 1) {
+			histogram.Bounds = append(histogram.Bounds, v.bound)
+		}
+		histogram.Counts = append(histogram.Counts, v.count-previous)
+		previous = v.count
+	}
+	// Without a +Inf bucket no packet was larger than the last bound
+	if !math.IsInf(buckets[len(buckets)-1].bound, 1) {
+		histogram.Counts = append(histogram.Counts, 0)
+	}
+
+	if sum, exists := metrics.Sample(name+"_sum", labels); exists {
+		histogram.Sum = sum.Value
+	}
+	return histogram
+}
+
+// Amount of packets of all buckets.
+func (histogram PacketSizeHistogram) Count() float64 {
+	count := 0.0
+	for _, v := range histogram.Counts {
+		count += v
+	}
+	return count
+}
+
+// Average size of a packet in bytes. Zero without packets.
+func (histogram PacketSizeHistogram) Mean() float64 {
+	count := histogram.Count()
+	if count == 0 {
+		return 0
+	}
+	return histogram.Sum / count
+}
+
+// Share (0.0 - 1.0) of the packets per bucket. Zero shares without packets.
+func (histogram PacketSizeHistogram) Shares() []float64 {
+	shares := make([]float64, len(histogram.Counts))
+	count := histogram.Count()
+	if count == 0 {
+		return shares
+	}
+	for i, v := range histogram.Counts {
+		shares[i] = v / count
+	}
+	return shares
+}
+
+// Readable labels of the buckets like '<=64' and '>9000'.
+func (histogram PacketSizeHistogram) Labels() []string {
+	labels := make([]string, 0, len(histogram.Counts))
+	for _, v := range histogram.Bounds {
+		labels = append(labels, "<="+strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	if len(histogram.Bounds) > 0 {
+		labels = append(labels, ">"+strconv.FormatFloat(histogram.Bounds[len(histogram.Bounds)-1], 'f', -1, 64))
+	} else if len(histogram.Counts) > 0 {
+		labels = append(labels, ">0")
+	}
+	return labels
+}
+
+// The packets added to the histogram between two polls. A histogram with other buckets cannot be compared and
+// counts as reset.
+func histogramDiff(start PacketSizeHistogram, end PacketSizeHistogram, reset bool) PacketSizeHistogram {
+	if !start.sameBuckets(end) {
+		return end
+	}
+
+	diff := PacketSizeHistogram{Bounds: end.Bounds, Counts: make([]float64, len(end.Counts))}
+	for i := range end.Counts {
+		diff.Counts[i] = counterDiff(start.Counts[i], end.Counts[i], reset)
+	}
+	diff.Sum = counterDiff(start.Sum, end.Sum, reset)
+	return diff
+}
+
+// Adds the packets of the other histogram. An empty histogram takes over the buckets of the other one, histograms
+// with other buckets are ignored.
+func (histogram *PacketSizeHistogram) Add(other PacketSizeHistogram) {
+	if len(histogram.Counts) == 0 {
+		histogram.Bounds = other.Bounds
+		histogram.Counts = append([]float64(nil), other.Counts...)
+		histogram.Sum = other.Sum
+		return
+	}
+	if !histogram.sameBuckets(other) {
+		return
+	}
+	for i, v := range other.Counts {
+		histogram.Counts[i] += v
+	}
+	histogram.Sum += other.Sum
+}
+
+// Checks if a histogram is going backwards, which is only possible after a reset.
+func (histogram PacketSizeHistogram) decreased(end PacketSizeHistogram) bool {
+	if !histogram.sameBuckets(end) {
+		return false
+	}
+	for i := range histogram.Counts {
+		if histogram.Counts[i] > end.Counts[i] {
+			return true
+		}
+	}
+	return histogram.Sum > end.Sum
+}
+
+func (histogram PacketSizeHistogram) sameBuckets(other PacketSizeHistogram) bool {
+	if len(histogram.Bounds) != len(other.Bounds) || len(histogram.Counts) != len(other.Counts) {
+		return false
+	}
+	for i := range histogram.Bounds {
+		if histogram.Bounds[i] != other.Bounds[i] {
+			return false
+		}
+	}
+	return true
+}
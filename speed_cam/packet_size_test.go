@@ -0,0 +1,78 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"reflect"
+	"testing"
+)
+
+func loadPacketSizes(t *testing.T, file int) PacketSizeHistogram {
+	counter = file
+	metrics, err := ParsePrometheusMetrics(loadPrometheusResult())
+	if err != nil {
+		t.Fatalf("error parsing metrics: %v", err)
+	}
+	return packetSizeHistogram(metrics, "border_input_pkt_size_bytes", map[string]string{"sock": "intf:16"})
+}
+
+func TestPacketSizeHistogram(t *testing.T) {
+	start := loadPacketSizes(t, 1)
+
+	expectedBounds := []float64{64, 256, 512, 1024, 1280, 1500, 3000, 6000, 9000}
+	if !reflect.DeepEqual(start.Bounds, expectedBounds) {
+		t.Errorf("Expected bounds %v, but was %v", expectedBounds, start.Bounds)
+	}
+	// The buckets are not cumulative
+	expectedCounts := []float64{89, 0, 0, 16, 0, 0, 0, 0, 0, 0}
+	if !reflect.DeepEqual(start.Counts, expectedCounts) {
+		t.Errorf("Expected counts %v, but was %v", expectedCounts, start.Counts)
+	}
+	if start.Count() != 105 || start.Sum != 16290 {
+		t.Errorf("Expected 105 packets with 16290 bytes, but was %v with %v", start.Count(), start.Sum)
+	}
+
+	diff := histogramDiff(start, loadPacketSizes(t, 2), false)
+	expectedCounts = []float64{75, 0, 0, 14, 0, 0, 0, 0, 0, 0}
+	if !reflect.DeepEqual(diff.Counts, expectedCounts) || diff.Sum != 14077 {
+		t.Errorf("Expected counts %v with 14077 bytes, but was %v", expectedCounts, diff)
+	}
+	if labels := diff.Labels(); labels[0] != "<=64" || labels[len(labels)-1] != ">9000" {
+		t.Errorf("Unexpected labels %v", labels)
+	}
+
+	// After a reset the current counts are the packets of the sample
+	diff = histogramDiff(loadPacketSizes(t, 2), start, true)
+	if !reflect.DeepEqual(diff.Counts, start.Counts) {
+		t.Errorf("Expected counts %v after reset, but was %v", start.Counts, diff.Counts)
+	}
+}
+
+func TestPacketSizeHistogramAdd(t *testing.T) {
+	bounds := []float64{64, 1500}
+	histogram := PacketSizeHistogram{}
+	histogram.Add(PacketSizeHistogram{Bounds: bounds, Counts: []float64{3, 1, 0}, Sum: 1000})
+	histogram.Add(PacketSizeHistogram{Bounds: bounds, Counts: []float64{1, 3, 0}, Sum: 3000})
+	// Other buckets are ignored
+	histogram.Add(PacketSizeHistogram{Bounds: []float64{64}, Counts: []float64{5, 5}, Sum: 5000})
+
+	if !reflect.DeepEqual(histogram.Counts, []float64{4, 4, 0}) || histogram.Mean() != 500 {
+		t.Errorf("Unexpected sum of histograms: %v", histogram)
+	}
+	if shares := histogram.Shares(); shares[0] != 0.5 || shares[1] != 0.5 {
+		t.Errorf("Expected shares of 0.5, but was %v", shares)
+	}
+}
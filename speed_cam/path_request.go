@@ -0,0 +1,140 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"fmt"
+	"github.com/scionproto/scion/go/lib/addr"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A hop of a path request. The interface ids are zero at the start and the end of the path.
+type PathRequestHop struct {
+	IsdAs addr.IA
+	// Interface the path enters the AS
+	IngressIfId int
+	// Interface the path leaves the AS
+	EgressIfId int
+}
+
+// A path request parsed into its hops in the order of the path.
+type PathRequest struct {
+	Hops []PathRequestHop
+}
+
+// The ASes of the path in the order of the path.
+func (request *PathRequest) IsdAses() []addr.IA {
+	isdAses := make([]addr.IA, 0, len(request.Hops))
+	for _, v := range request.Hops {
+		isdAses = append(isdAses, v.IsdAs)
+	}
+	return isdAses
+}
+
+// The reason why a path request is malformed.
+type PathRequestErrorKind string
+
+const (
+	PathRequestEmpty             PathRequestErrorKind = "no hops"
+	PathRequestInvalidIsdAs      PathRequestErrorKind = "an invalid ISD-AS"
+	PathRequestInvalidInterfaces PathRequestErrorKind = "invalid interfaces"
+	PathRequestMissingIsdAs      PathRequestErrorKind = "a missing ISD-AS"
+	PathRequestMissingInterfaces PathRequestErrorKind = "missing interfaces"
+)
+
+// A malformed path request.
+type PathRequestError struct {
+	Kind    PathRequestErrorKind
+	Request string
+	// The malformed token and its index in the whitespace separated request. Empty and -1 for an empty request
+	Token    string
+	Position int
+}
+
+func (err *PathRequestError) Error() string {
+	if err.Position < 0 {
+		return fmt.Sprintf("Path request has %v. Request:%s", err.Kind, err.Request)
+	}
+	return fmt.Sprintf("Path request has %v '%v' at token %v. Request:%s", err.Kind, err.Token, err.Position,
+		err.Request)
+}
+
+var (
+	// Legacy format 1-10 and new format 1-ff00:0:110
+	isdAsRegex      = regexp.MustCompile(`^\d+-(\d+|[0-9a-fA-F]{1,4}:[0-9a-fA-F]{1,4}:[0-9a-fA-F]{1,4})$`)
+	interfacesRegex = regexp.MustCompile(`^(\d+)>(\d+)$`)
+)
+
+// Parses a path request. Its ASes are separated by the interface pairs between them, the egress interface of the
+// previous AS and the ingress interface of the next AS.
+// Input format is: ISD-AS /d>/d ISD-AS
+// Example: 1-1 1>1 1-5 4>3 1-ff00:0:110
+func ParsePathRequest(pathRequest string) (*PathRequest, error) {
+	tokens := strings.Fields(pathRequest)
+	if len(tokens) == 0 {
+		return nil, &PathRequestError{Kind: PathRequestEmpty, Request: pathRequest, Position: -1}
+	}
+
+	request := new(PathRequest)
+	ingressIfId := 0
+	for i, token := range tokens {
+		newError := func(kind PathRequestErrorKind) error {
+			return &PathRequestError{Kind: kind, Request: pathRequest, Token: token, Position: i}
+		}
+
+		// ASes on even and interfaces on odd positions
+		if i%2 == 0 {
+			if interfacesRegex.MatchString(token) {
+				return nil, newError(PathRequestMissingIsdAs)
+			}
+			if !isdAsRegex.MatchString(token) {
+				return nil, newError(PathRequestInvalidIsdAs)
+			}
+			isdAs, err := addr.IAFromString(token)
+			if err != nil {
+				return nil, newError(PathRequestInvalidIsdAs)
+			}
+			request.Hops = append(request.Hops, PathRequestHop{IsdAs: isdAs, IngressIfId: ingressIfId})
+			continue
+		}
+
+		matches := interfacesRegex.FindStringSubmatch(token)
+		if matches == nil {
+			if isdAsRegex.MatchString(token) {
+				return nil, newError(PathRequestMissingInterfaces)
+			}
+			return nil, newError(PathRequestInvalidInterfaces)
+		}
+		egressIfId, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, newError(PathRequestInvalidInterfaces)
+		}
+		ingressIfId, err = strconv.Atoi(matches[2])
+		if err != nil {
+			return nil, newError(PathRequestInvalidInterfaces)
+		}
+		request.Hops[len(request.Hops)-1].EgressIfId = egressIfId
+	}
+
+	// A path ends with an AS, not with interfaces
+	if len(tokens)%2 == 0 {
+		return nil, &PathRequestError{Kind: PathRequestMissingIsdAs, Request: pathRequest,
+			Token: tokens[len(tokens)-1], Position: len(tokens) - 1}
+	}
+	return request, nil
+}
@@ -15,17 +15,19 @@
 // Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
 package speed_cam
 
+import "context"
+
 type PathRequestFetcher interface {
-	FetchPathRequests() ([]string, error)
+	FetchPathRequests(ctx context.Context) ([]string, error)
 }
 
 type PathRequestRestFetcher struct {
 	FetchUrl string
 }
 
-func (fetcher PathRequestRestFetcher) FetchPathRequests() ([]string, error) {
+func (fetcher PathRequestRestFetcher) FetchPathRequests(ctx context.Context) ([]string, error) {
 
 	result := make([]string, 0)
-	err := FetchJsonData(fetcher.FetchUrl, &result)
+	err := FetchJsonData(ctx, fetcher.FetchUrl, &result)
 	return result, err
 }
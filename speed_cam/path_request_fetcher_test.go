@@ -16,6 +16,7 @@
 package speed_cam
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -42,7 +43,7 @@ func TestSCPBFetch(t *testing.T) {
 	fetcher := PathRequestRestFetcher{
 		FetchUrl: ts.URL + "/pathServerRequests",
 	}
-	requests, err := fetcher.FetchPathRequests()
+	requests, err := fetcher.FetchPathRequests(context.Background())
 
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -0,0 +1,92 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/scionproto/scion/go/lib/addr"
+	"testing"
+)
+
+func TestParsePathRequest(t *testing.T) {
+	request, err := ParsePathRequest(" 1-10 2>1 1-ff00:0:110  4>3 2-20 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	as110, _ := addr.IAFromString("1-10")
+	asNew, _ := addr.IAFromString("1-ff00:0:110")
+	as220, _ := addr.IAFromString("2-20")
+	expected := []PathRequestHop{
+		{IsdAs: as110, IngressIfId: 0, EgressIfId: 2},
+		{IsdAs: asNew, IngressIfId: 1, EgressIfId: 4},
+		{IsdAs: as220, IngressIfId: 3, EgressIfId: 0},
+	}
+	if len(request.Hops) != len(expected) {
+		t.Fatalf("Expected %v, but was %v", expected, request.Hops)
+	}
+	for i, v := range expected {
+		if request.Hops[i] != v {
+			t.Errorf("Expected hop %v, but was %v", v, request.Hops[i])
+		}
+	}
+}
+
+func TestParseMalformedPathRequest(t *testing.T) {
+	malformed := map[string]PathRequestErrorKind{
+		"  ":               PathRequestEmpty,
+		"1-10 1>1 x-11":    PathRequestInvalidIsdAs,
+		"1-10 1>1 1-g:0:1": PathRequestInvalidIsdAs,
+		"1-10 1-11":        PathRequestMissingInterfaces,
+		"1-10 1>x 1-11":    PathRequestInvalidInterfaces,
+		"1-10 1>1 2>2":     PathRequestMissingIsdAs,
+		"1-10 1>1":         PathRequestMissingIsdAs,
+	}
+	for request, kind := range malformed {
+		_, err := ParsePathRequest(request)
+		requestErr, ok := err.(*PathRequestError)
+		if !ok {
+			t.Errorf("Expected a PathRequestError for '%v', but was %v", request, err)
+			continue
+		}
+		if requestErr.Kind != kind {
+			t.Errorf("Expected %v for '%v', but was %v", kind, request, requestErr.Kind)
+		}
+	}
+}
+
+// Parallel links between the same ASes are told apart by their interfaces
+func TestHandlePathRequestInterfaces(t *testing.T) {
+	inspector := CreateEmptyGraph(Default())
+	if err := inspector.HandlePathRequest("1-10 1>2 1-11"); err != nil {
+		t.Fatal(err)
+	}
+	if err := inspector.HandlePathRequest("1-11 3>4 1-10"); err != nil {
+		t.Fatal(err)
+	}
+
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	links := inspector.graph.Snapshot().Links()
+	if len(links) != 2 || links[0] != NewLinkKey(as110, 1, as111, 2) || links[1] != NewLinkKey(as110, 4, as111, 3) {
+		t.Errorf("Expected the links 1-10#1<->1-11#2 and 1-10#4<->1-11#3, but was %v", links)
+	}
+	if degree := inspector.graph.Snapshot().nodes[as110].info.degree; degree != 1 {
+		t.Errorf("Expected degree 1 of 1-10, but was %v", degree)
+	}
+	if err := inspector.HandlePathRequest("1-10 1>2"); err == nil {
+		t.Error("Expected an error for a malformed path request")
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Schedules the polls of BR metrics endpoints shared by all SpeedCams. It limits the concurrent polls in total and
+// per host. Polls of the same endpoint are deduplicated: a running poll or one started less than the jitter ago
+// serves every consumer.
+type PollScheduler struct {
+	// Semaphore for all polls. Nil for no limit
+	global    chan struct{}
+	hostLimit int
+	jitter    time.Duration
+	// Source of the start delays
+	random *rand.Rand
+
+	mutex sync.Mutex
+	// Semaphores per host
+	hosts map[string]chan struct{}
+	// Latest poll per URL
+	polls map[string]*scheduledPoll
+}
+
+type scheduledPoll struct {
+	// Closed when the poll finished
+	done      chan struct{}
+	timestamp time.Time
+	data      []byte
+	err       error
+}
+
+// Creates a scheduler. A concurrency of zero or less stands for no limit, a jitter of zero for neither delayed poll
+// starts nor reused polls. The start delays are drawn from a random stream of the seed.
+func CreatePollScheduler(concurrency int, hostConcurrency int, jitter time.Duration, seed int64) *PollScheduler {
+	scheduler := &PollScheduler{hostLimit: hostConcurrency, jitter: jitter, random: newRandom(seed, randomStreamPoll)}
+	if concurrency > 0 {
+		scheduler.global = make(chan struct{}, concurrency)
+	}
+	scheduler.hosts = make(map[string]chan struct{})
+	scheduler.polls = make(map[string]*scheduledPoll)
+	return scheduler
+}
+
+// Creates the scheduler with the limits and the seed of the config.
+func CreatePollSchedulerFromConfig(config *SpeedCamConfig) *PollScheduler {
+	return CreatePollScheduler(config.PollConcurrency, config.PollHostConcurrency,
+		time.Duration(config.PollJitter)*time.Millisecond, config.Seed)
+}
+
+// A random delay for the first poll of a measurement, so the SpeedCams do not poll at the same instant.
+func (scheduler *PollScheduler) StartDelay() time.Duration {
+	if scheduler.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(scheduler.random.Int63n(int64(scheduler.jitter)))
+}
+
+// Fetches the URL on the host, or joins a poll of the same URL. Returns the data and the time the poll started.
+func (scheduler *PollScheduler) Poll(ctx context.Context, host string, url string) ([]byte, time.Time, error) {
+	scheduler.mutex.Lock()
+	poll, exists := scheduler.polls[url]
+	owner := !exists || !poll.reusable(time.Now(), scheduler.jitter)
+	if owner {
+		poll = &scheduledPoll{done: make(chan struct{})}
+		scheduler.polls[url] = poll
+	}
+	scheduler.mutex.Unlock()
+
+	if owner {
+		scheduler.run(ctx, host, url, poll)
+	}
+
+	select {
+	case <-poll.done:
+		return poll.data, poll.timestamp, poll.err
+	case <-ctx.Done():
+		return nil, time.Time{}, ctx.Err()
+	}
+}
+
+func (scheduler *PollScheduler) run(ctx context.Context, host string, url string, poll *scheduledPoll) {
+	defer close(poll.done)
+
+	release, err := scheduler.acquire(ctx, host)
+	if err != nil {
+		poll.err = err
+		return
+	}
+	defer release()
+
+	poll.timestamp = time.Now()
+	poll.data, poll.err = FetchData(ctx, url)
+}
+
+// Acquires a slot of the host and a global slot. The returned function releases both.
+func (scheduler *PollScheduler) acquire(ctx context.Context, host string) (func(), error) {
+	hostSlots := scheduler.hostSlots(host)
+	if err := acquireSlot(ctx, hostSlots); err != nil {
+		return nil, err
+	}
+	if err := acquireSlot(ctx, scheduler.global); err != nil {
+		releaseSlot(hostSlots)
+		return nil, err
+	}
+	return func() {
+		releaseSlot(scheduler.global)
+		releaseSlot(hostSlots)
+	}, nil
+}
+
+func (scheduler *PollScheduler) hostSlots(host string) chan struct{} {
+	if scheduler.hostLimit <= 0 {
+		return nil
+	}
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	slots, exists := scheduler.hosts[host]
+	if !exists {
+		slots = make(chan struct{}, scheduler.hostLimit)
+		scheduler.hosts[host] = slots
+	}
+	return slots
+}
+
+func acquireSlot(ctx context.Context, slots chan struct{}) error {
+	if slots == nil {
+		return nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func releaseSlot(slots chan struct{}) {
+	if slots != nil {
+		<-slots
+	}
+}
+
+// A running poll is always joined, a finished one only if it succeeded and is younger than the max age.
+func (poll *scheduledPoll) reusable(now time.Time, maxAge time.Duration) bool {
+	select {
+	case <-poll.done:
+		return poll.err == nil && now.Sub(poll.timestamp) < maxAge
+	default:
+		return true
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Serves slow requests and records the amount of requests and the maximum of concurrent ones
+type countingServer struct {
+	requests   int32
+	running    int32
+	maxRunning int32
+}
+
+func (server *countingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&server.requests, 1)
+	running := atomic.AddInt32(&server.running, 1)
+	for {
+		max := atomic.LoadInt32(&server.maxRunning)
+		if running <= max || atomic.CompareAndSwapInt32(&server.maxRunning, max, running) {
+			break
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	atomic.AddInt32(&server.running, -1)
+	w.Write([]byte(r.URL.Path))
+}
+
+func pollConcurrently(scheduler *PollScheduler, urls []string) {
+	var wait sync.WaitGroup
+	for _, url := range urls {
+		wait.Add(1)
+		go func(url string) {
+			defer wait.Done()
+			scheduler.Poll(context.Background(), "localhost", url)
+		}(url)
+	}
+	wait.Wait()
+}
+
+func TestPollSchedulerDeduplication(t *testing.T) {
+	server := &countingServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	scheduler := CreatePollScheduler(0, 0, time.Second, 0)
+	pollConcurrently(scheduler, []string{ts.URL + "/metrics", ts.URL + "/metrics", ts.URL + "/metrics"})
+	if server.requests != 1 {
+		t.Errorf("Expected 1 request for concurrent polls, but was %v", server.requests)
+	}
+
+	// A recent poll is reused
+	data, _, err := scheduler.Poll(context.Background(), "localhost", ts.URL+"/metrics")
+	if err != nil || string(data) != "/metrics" || server.requests != 1 {
+		t.Errorf("Expected the recent poll to be reused, requests: %v, err: %v", server.requests, err)
+	}
+
+	// Without jitter only running polls are shared
+	scheduler = CreatePollScheduler(0, 0, 0, 0)
+	scheduler.Poll(context.Background(), "localhost", ts.URL+"/metrics")
+	if server.requests != 2 {
+		t.Errorf("Expected 2 requests, but was %v", server.requests)
+	}
+}
+
+func TestPollSchedulerHostLimit(t *testing.T) {
+	server := &countingServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	scheduler := CreatePollScheduler(0, 2, 0, 0)
+	pollConcurrently(scheduler, []string{ts.URL + "/1", ts.URL + "/2", ts.URL + "/3", ts.URL + "/4", ts.URL + "/5"})
+	if server.requests != 5 {
+		t.Errorf("Expected 5 requests, but was %v", server.requests)
+	}
+	if server.maxRunning > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, but was %v", server.maxRunning)
+	}
+}
+
+func TestPollSchedulerCancelled(t *testing.T) {
+	server := &countingServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	// The only slot is taken by another poll
+	scheduler := CreatePollScheduler(1, 0, 0, 0)
+	go scheduler.Poll(context.Background(), "localhost", ts.URL+"/1")
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := scheduler.Poll(ctx, "localhost", ts.URL+"/2")
+	if err != context.Canceled {
+		t.Errorf("Expected the poll to be cancelled, but was %v", err)
+	}
+}
+
+func TestPollSchedulerSeed(t *testing.T) {
+	config := Default()
+	config.Seed = 42
+	config.PollJitter = 1000
+	first := CreatePollSchedulerFromConfig(config)
+	second := CreatePollSchedulerFromConfig(config)
+	for i := 0; i < 10; i++ {
+		if a, b := first.StartDelay(), second.StartDelay(); a != b {
+			t.Fatalf("Expected the same start delays for the same seed, but were %v and %v", a, b)
+		}
+	}
+}
@@ -15,28 +15,89 @@
 // Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
 package speed_cam
 
-import "time"
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
 
-var ProgramRunning = true
-
-func RunProgram(config *SpeedCamConfig, requestFetchUrl string, borderRouterFetchUrl string) {
+// Runs the inspection loop till the context is cancelled. A running inspection is finished with its partial results.
+// Without URLs, the data must be pushed to the ingest address of the config.
+func RunProgram(ctx context.Context, config *SpeedCamConfig, requestFetchUrl string, borderRouterFetchUrl string) {
+	if err := config.Validate(); err != nil {
+		MyLogger.Criticalf("invalid config, err: %v", err)
+		return
+	}
 	// Initiate the speed cam algorithm
-	inspector := CreateEmptyGraph(config)
-	requestRestFetcher := PathRequestRestFetcher{FetchUrl: requestFetchUrl}
+	inspector, err := CreateFromState(config)
+	if err != nil {
+		// Starting empty would overwrite the state file with the next checkpoint
+		MyLogger.Criticalf("error restoring state, err: %v", err)
+		return
+	}
+	var requestRestFetcher PathRequestFetcher
+	if len(requestFetchUrl) != 0 {
+		requestRestFetcher = PathRequestRestFetcher{FetchUrl: requestFetchUrl}
+	}
 	borderRouterInfoFetcher := PrometheusClientFetcher{FetcherResource: borderRouterFetchUrl}
+	err = inspector.LoadCapacities()
+	if err != nil {
+		MyLogger.Errorf("error loading link capacities, err: %v", err)
+	}
+	err = inspector.LoadTopology()
+	if err != nil {
+		MyLogger.Errorf("error loading topology, err: %v", err)
+	}
 
 	//Start speed cam algorithm
-	go inspector.Start(requestRestFetcher, borderRouterInfoFetcher)
+	go inspector.Start(ctx, requestRestFetcher, borderRouterInfoFetcher)
 
 	MyLogger.Debug("Wait 2 seconds before starting the inspection...")
-	time.Sleep(2 * time.Second)
+	if sleep(ctx, 2*time.Second) {
+		MyLogger.Debug("Starting inspection loop...")
+		for ctx.Err() == nil {
+			inspector.StartInspection(ctx)
+			sleepTime := getWaitTime(inspector)
+			MyLogger.Debugf("Sleep for '%v' till next inspection", sleepTime)
+			sleep(ctx, sleepTime)
+		}
+		MyLogger.Debug("Finished loop!")
+	}
+
+	// Keep the history of the last inspection for the next start
+	err = inspector.Checkpoint()
+	if err != nil {
+		MyLogger.Errorf("error writing checkpoint, err: %v", err)
+	}
+}
+
+// Creates a context which is cancelled on SIGINT or SIGTERM.
+func SignalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			MyLogger.Infof("Received %v, shutting down...", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+
+	return ctx, cancel
+}
 
-	MyLogger.Debug("Starting inspection loop...")
-	for ProgramRunning {
-		inspector.StartInspection()
-		sleepTime := getWaitTime(inspector)
-		MyLogger.Debugf("Sleep for '%v' till next inspection", sleepTime)
-		time.Sleep(sleepTime)
+// Sleeps for the duration or till the context is cancelled. Returns false if it was cancelled.
+func sleep(ctx context.Context, duration time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(duration):
+		return true
 	}
-	MyLogger.Debug("Finished loop!")
 }
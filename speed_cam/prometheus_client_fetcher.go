@@ -16,6 +16,7 @@
 package speed_cam
 
 import (
+	"context"
 	"github.com/scionproto/scion/go/lib/addr"
 	"strconv"
 )
@@ -25,9 +26,9 @@ type PrometheusClientFetcher struct {
 	Info            []PrometheusClientInfo
 }
 
-func (fetcher *PrometheusClientFetcher) PollData() error {
+func (fetcher *PrometheusClientFetcher) PollData(ctx context.Context) error {
 
-	err := FetchJsonData(fetcher.FetcherResource, &fetcher.Info)
+	err := FetchJsonData(ctx, fetcher.FetcherResource, &fetcher.Info)
 	return err
 }
 
@@ -37,8 +38,15 @@ type PrometheusClientInfo struct {
 	BrId        string
 	SourceIsdAs addr.IA
 	TargetIsdAs addr.IA
+	// Id of the BR interface facing the target AS. Its metrics are labeled with sock="intf:<IfId>"
+	IfId int
 }
 
 func (info *PrometheusClientInfo) URL() string {
 	return "http://" + info.Ip + ":" + strconv.FormatInt(int64(info.Port), 10)
 }
+
+// The value of the sock label of the metrics of the interface facing the target AS.
+func (info *PrometheusClientInfo) Socket() string {
+	return "intf:" + strconv.FormatInt(int64(info.IfId), 10)
+}
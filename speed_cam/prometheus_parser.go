@@ -0,0 +1,297 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A single sample line of the Prometheus text exposition format.
+// Example: border_input_bytes_total{elem="br1-10-1",sock="intf:16"} 16290
+type PrometheusSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	// Zero if the sample line had no timestamp
+	Timestamp time.Time
+}
+
+// A metric family with its HELP and TYPE information and all of its samples. Samples of histograms and summaries
+// (_bucket, _sum and _count) are assigned to the family they belong to.
+type PrometheusMetric struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []PrometheusSample
+}
+
+// All metric families of a Prometheus text exposition, identified by their name
+type PrometheusMetrics map[string]*PrometheusMetric
+
+// Parses a document in the Prometheus text exposition format (version 0.0.4).
+func ParsePrometheusMetrics(data []byte) (PrometheusMetrics, error) {
+	metrics := make(PrometheusMetrics)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		var err error
+		if strings.HasPrefix(line, "#") {
+			err = metrics.parseComment(line)
+		} else {
+			err = metrics.parseSample(line)
+		}
+		if err != nil {
+			return metrics, errors.New(fmt.Sprintf("line %v: %v", lineNumber, err))
+		}
+	}
+
+	return metrics, scanner.Err()
+}
+
+// Returns the first sample with the given name containing all of the given labels.
+func (metrics PrometheusMetrics) Sample(name string, labels map[string]string) (PrometheusSample, bool) {
+	metric, exists := metrics[metrics.familyName(name)]
+	if !exists {
+		return PrometheusSample{}, false
+	}
+
+	for _, sample := range metric.Samples {
+		if sample.Name == name && sample.hasLabels(labels) {
+			return sample, true
+		}
+	}
+	return PrometheusSample{}, false
+}
+
+// Returns all samples with the given name containing all of the given labels.
+func (metrics PrometheusMetrics) Samples(name string, labels map[string]string) []PrometheusSample {
+	var samples []PrometheusSample
+	metric, exists := metrics[metrics.familyName(name)]
+	if !exists {
+		return samples
+	}
+
+	for _, sample := range metric.Samples {
+		if sample.Name == name && sample.hasLabels(labels) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// Returns all distinct values of a label used by the samples of the given name.
+func (metrics PrometheusMetrics) LabelValues(name string, label string) []string {
+	var values []string
+	metric, exists := metrics[metrics.familyName(name)]
+	if !exists {
+		return values
+	}
+
+	seen := make(map[string]bool)
+	for _, sample := range metric.Samples {
+		value, exists := sample.Labels[label]
+		if sample.Name != name || !exists || seen[value] {
+			continue
+		}
+		seen[value] = true
+		values = append(values, value)
+	}
+	return values
+}
+
+func (sample *PrometheusSample) hasLabels(labels map[string]string) bool {
+	for k, v := range labels {
+		if sample.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Histograms and summaries expose their samples with suffixes. They belong to the family without the suffix.
+func (metrics PrometheusMetrics) familyName(sampleName string) string {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if !strings.HasSuffix(sampleName, suffix) {
+			continue
+		}
+		family := strings.TrimSuffix(sampleName, suffix)
+		if metric, exists := metrics[family]; exists && (metric.Type == "histogram" || metric.Type == "summary") {
+			return family
+		}
+	}
+	return sampleName
+}
+
+func (metrics PrometheusMetrics) metric(name string) *PrometheusMetric {
+	metric, exists := metrics[name]
+	if !exists {
+		metric = &PrometheusMetric{Name: name, Type: "untyped"}
+		metrics[name] = metric
+	}
+	return metric
+}
+
+// Parses "# HELP name text" and "# TYPE name type". Other comments are ignored.
+func (metrics PrometheusMetrics) parseComment(line string) error {
+	fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "#")), " ", 3)
+	if len(fields) < 2 || (fields[0] != "HELP" && fields[0] != "TYPE") {
+		return nil
+	}
+
+	metric := metrics.metric(fields[1])
+	text := ""
+	if len(fields) == 3 {
+		text = strings.TrimSpace(fields[2])
+	}
+
+	if fields[0] == "HELP" {
+		metric.Help = unescapeHelp(text)
+		return nil
+	}
+
+	switch text {
+	case "counter", "gauge", "histogram", "summary", "untyped":
+		metric.Type = text
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("unknown metric type '%v' of %v", text, fields[1]))
+	}
+}
+
+// Parses a sample line: name{label="value",...} value [timestamp]
+func (metrics PrometheusMetrics) parseSample(line string) error {
+	sample := PrometheusSample{Labels: make(map[string]string)}
+
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd == -1 {
+		return errors.New(fmt.Sprintf("sample without value: '%v'", line))
+	}
+	sample.Name = line[:nameEnd]
+	if len(sample.Name) == 0 {
+		return errors.New(fmt.Sprintf("sample without name: '%v'", line))
+	}
+
+	rest := line[nameEnd:]
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		rest, err = parseLabels(rest[1:], sample.Labels)
+		if err != nil {
+			return err
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return errors.New(fmt.Sprintf("invalid value of sample '%v'", sample.Name))
+	}
+
+	value, err := parsePrometheusFloat(fields[0])
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid value of sample '%v': %v", sample.Name, err))
+	}
+	sample.Value = value
+
+	if len(fields) == 2 {
+		millis, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid timestamp of sample '%v': %v", sample.Name, err))
+		}
+		sample.Timestamp = time.Unix(0, millis*int64(time.Millisecond))
+	}
+
+	metric := metrics.metric(metrics.familyName(sample.Name))
+	metric.Samples = append(metric.Samples, sample)
+	return nil
+}
+
+// Parses the labels after the opening brace till the closing brace and returns the remaining line.
+func parseLabels(line string, labels map[string]string) (string, error) {
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(line, "}") {
+			return line[1:], nil
+		}
+
+		equals := strings.Index(line, "=")
+		if equals == -1 {
+			return line, errors.New(fmt.Sprintf("label without value: '%v'", line))
+		}
+		name := strings.TrimSpace(line[:equals])
+		line = strings.TrimLeft(line[equals+1:], " \t")
+		if len(name) == 0 || !strings.HasPrefix(line, "\"") {
+			return line, errors.New(fmt.Sprintf("invalid label '%v'", name))
+		}
+
+		// Read the quoted value with its escape sequences
+		var value bytes.Buffer
+		i := 1
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] != '\\' || i+1 == len(line) {
+				value.WriteByte(line[i])
+				continue
+			}
+			i++
+			switch line[i] {
+			case 'n':
+				value.WriteByte('\n')
+			default:
+				value.WriteByte(line[i])
+			}
+		}
+		if i == len(line) {
+			return line, errors.New(fmt.Sprintf("unterminated value of label '%v'", name))
+		}
+		labels[name] = value.String()
+
+		line = strings.TrimLeft(line[i+1:], " \t")
+		if strings.HasPrefix(line, ",") {
+			line = line[1:]
+		} else if !strings.HasPrefix(line, "}") {
+			return line, errors.New(fmt.Sprintf("missing separator after label '%v'", name))
+		}
+	}
+}
+
+func parsePrometheusFloat(value string) (float64, error) {
+	switch value {
+	case "+Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	default:
+		return strconv.ParseFloat(value, 64)
+	}
+}
+
+func unescapeHelp(text string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(text)
+}
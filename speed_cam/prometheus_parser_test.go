@@ -0,0 +1,117 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParsePrometheusFile(t *testing.T) {
+	data, err := ioutil.ReadFile("../test_resources/prometheus_result_1.txt")
+	if err != nil {
+		t.Fatalf("error reading test resource: %v", err)
+	}
+
+	metrics, err := ParsePrometheusMetrics(data)
+	if err != nil {
+		t.Fatalf("error parsing metrics: %v", err)
+	}
+
+	metric := metrics["border_input_bytes_total"]
+	if metric == nil || metric.Type != "counter" || metric.Help != "Total number of input bytes received." {
+		t.Fatalf("Unexpected metric border_input_bytes_total: %v", metric)
+	}
+	if len(metric.Samples) != 2 {
+		t.Errorf("Expected 2 samples, but was %v", len(metric.Samples))
+	}
+
+	// The order of the lines must not matter
+	sample, exists := metrics.Sample("border_input_bytes_total", map[string]string{"sock": "intf:16"})
+	if !exists || sample.Value != 16290 {
+		t.Errorf("Expected intf:16 value 16290, but was %v (exists: %v)", sample.Value, exists)
+	}
+	sample, exists = metrics.Sample("border_input_bytes_total", map[string]string{"sock": "loc:0"})
+	if !exists || sample.Value != 21489 {
+		t.Errorf("Expected loc:0 value 21489, but was %v (exists: %v)", sample.Value, exists)
+	}
+
+	// Histogram samples belong to their family
+	histogram := metrics["border_input_pkt_size_bytes"]
+	if histogram == nil || histogram.Type != "histogram" {
+		t.Fatalf("Unexpected histogram border_input_pkt_size_bytes: %v", histogram)
+	}
+	sample, exists = metrics.Sample("border_input_pkt_size_bytes_bucket",
+		map[string]string{"sock": "intf:16", "le": "+Inf"})
+	if !exists || sample.Value != 105 {
+		t.Errorf("Expected +Inf bucket value 105, but was %v (exists: %v)", sample.Value, exists)
+	}
+
+	sample, _ = metrics.Sample("process_start_time_seconds", nil)
+	if sample.Value != 1.52085915379e+09 {
+		t.Errorf("Expected process start time 1.52085915379e+09, but was %v", sample.Value)
+	}
+}
+
+func TestParsePrometheusSample(t *testing.T) {
+	data := []byte("# TYPE test_metric gauge\n" +
+		"test_metric{path=\"C:\\\\dir\",quote=\"say \\\"hi\\\"\",} +Inf 1520859153790\n" +
+		"test_metric NaN\n")
+
+	metrics, err := ParsePrometheusMetrics(data)
+	if err != nil {
+		t.Fatalf("error parsing metrics: %v", err)
+	}
+
+	sample, exists := metrics.Sample("test_metric", map[string]string{"path": "C:\\dir", "quote": "say \"hi\""})
+	if !exists {
+		t.Fatalf("Sample with escaped labels not found: %v", metrics["test_metric"])
+	}
+	if !math.IsInf(sample.Value, 1) {
+		t.Errorf("Expected +Inf, but was %v", sample.Value)
+	}
+	expectedTime := time.Unix(1520859153, 790*int64(time.Millisecond))
+	if !sample.Timestamp.Equal(expectedTime) {
+		t.Errorf("Expected timestamp %v, but was %v", expectedTime, sample.Timestamp)
+	}
+
+	sample, _ = metrics.Sample("test_metric", map[string]string{})
+	if !math.IsInf(sample.Value, 1) {
+		t.Errorf("Expected first sample, but was %v", sample)
+	}
+	if len(metrics["test_metric"].Samples) != 2 || !math.IsNaN(metrics["test_metric"].Samples[1].Value) {
+		t.Errorf("Expected second sample NaN, but was %v", metrics["test_metric"].Samples)
+	}
+}
+
+func TestParsePrometheusInvalid(t *testing.T) {
+	invalid := []string{
+		"test_metric{sock=\"intf:16\" 1",
+		"test_metric{sock=intf} 1",
+		"test_metric",
+		"test_metric abc",
+		"# TYPE test_metric unknown",
+	}
+
+	for _, v := range invalid {
+		_, err := ParsePrometheusMetrics([]byte(v))
+		if err == nil {
+			t.Errorf("Expected error for '%v'", v)
+		}
+	}
+}
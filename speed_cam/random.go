@@ -0,0 +1,52 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Every user of randomness draws from its own stream of the seed, so concurrent users like the fetch loops do not
+// change the random numbers of the selection.
+const (
+	randomStreamSelection int64 = iota + 1
+	randomStreamInterval
+	randomStreamPoll
+	randomStreamFetch
+)
+
+// Creates a random generator, safe for concurrent use, for the stream of the seed.
+func newRandom(seed int64, stream int64) *rand.Rand {
+	return rand.New(&lockedSource{source: rand.NewSource(seed*31 + stream)})
+}
+
+type lockedSource struct {
+	lock   sync.Mutex
+	source rand.Source
+}
+
+func (source *lockedSource) Int63() int64 {
+	source.lock.Lock()
+	defer source.lock.Unlock()
+	return source.source.Int63()
+}
+
+func (source *lockedSource) Seed(seed int64) {
+	source.lock.Lock()
+	defer source.lock.Unlock()
+	source.source.Seed(seed)
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"math"
+	"sort"
+)
+
+// The features the candidate score is calculated of. Depending on the context they are the raw values or normalized
+// across all candidates.
+type ScoreFeatures struct {
+	Degree   float64
+	Capacity float64
+	Activity float64
+	// Success rate of the previous episodes
+	Success        float64
+	Betweenness    float64
+	PathCentrality float64
+}
+
+// Pointers to all features, so they can be normalized one after the other.
+func (features *ScoreFeatures) values() []*float64 {
+	return []*float64{&features.Degree, &features.Capacity, &features.Activity, &features.Success,
+		&features.Betweenness, &features.PathCentrality}
+}
+
+// The features multiplied by their weights of the config, i.e. their contributions to the score.
+func (features *ScoreFeatures) weighted(config *SpeedCamConfig) ScoreFeatures {
+	return ScoreFeatures{
+		Degree:         features.Degree * config.WeightDegree,
+		Capacity:       features.Capacity * config.WeightCapacity,
+		Activity:       features.Activity * config.WeightActivity,
+		Success:        features.Success * config.WeightSuccess,
+		Betweenness:    features.Betweenness * config.WeightBetweenness,
+		PathCentrality: features.PathCentrality * config.WeightPathCentrality,
+	}
+}
+
+// The sum of the features multiplied by their weights of the config.
+func (features *ScoreFeatures) weightedSum(config *SpeedCamConfig) float64 {
+	weighted := features.weighted(config)
+	sum := 0.0
+	for _, v := range weighted.values() {
+		sum += *v
+	}
+	return sum
+}
+
+// Normalizes every feature across the candidates with the score normalization of the config, so features of
+// different magnitudes are comparable before the weights are applied. The normalization 'none' keeps the raw values.
+func normalizeFeatures(candidates []*speedCamCandidate, config *SpeedCamConfig) {
+	var normalize func(values []float64) []float64
+	switch config.ScoreNormalization {
+	case "none":
+		normalize = func(values []float64) []float64 {
+			return values
+		}
+	case "minmax":
+		normalize = normalizeMinMax
+	case "zscore":
+		normalize = normalizeZScore
+	case "rank":
+		normalize = normalizeRank
+	default:
+		MyLogger.Panicf("Unsupported score normalization '%v'", config.ScoreNormalization)
+	}
+
+	for _, v := range candidates {
+		v.features = v.raw
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	for i := range candidates[0].raw.values() {
+		values := make([]float64, len(candidates))
+		for j, v := range candidates {
+			values[j] = *v.raw.values()[i]
+		}
+		for j, v := range normalize(values) {
+			*candidates[j].features.values()[i] = v
+		}
+	}
+}
+
+// Scales the values to 0.0 - 1.0. Equal values do not tell the candidates apart and are all zero.
+func normalizeMinMax(values []float64) []float64 {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+
+	result := make([]float64, len(values))
+	if max == min {
+		return result
+	}
+	for i, v := range values {
+		result[i] = (v - min) / (max - min)
+	}
+	return result
+}
+
+// Scales the values to their standard score, the distance to the mean in standard deviations. Equal values are all
+// zero.
+func normalizeZScore(values []float64) []float64 {
+	mean := 0.0
+	for _, v := range values {
+		mean += v / float64(len(values))
+	}
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean) / float64(len(values))
+	}
+
+	result := make([]float64, len(values))
+	deviation := math.Sqrt(variance)
+	if deviation == 0 {
+		return result
+	}
+	for i, v := range values {
+		result[i] = (v - mean) / deviation
+	}
+	return result
+}
+
+// Replaces the values by their rank scaled to 0.0 - 1.0, the highest value is one. Equal values share their average
+// rank, so a single value is 0.5. Outliers do not compress the other values like with min-max.
+func normalizeRank(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+
+	result := make([]float64, len(values))
+	if len(values) == 1 {
+		result[0] = 0.5
+		return result
+	}
+	for i := 0; i < len(order); {
+		// The equal values i..j-1 share the average of their ranks
+		j := i + 1
+		for j < len(order) && values[order[j]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j-1) / 2 / float64(len(values)-1)
+		for k := i; k < j; k++ {
+			result[order[k]] = rank
+		}
+		i = j
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/scionproto/scion/go/lib/addr"
+	"math"
+	"testing"
+	"time"
+)
+
+func assertValues(t *testing.T, method string, expected []float64, actual []float64) {
+	for i := range expected {
+		if math.Abs(expected[i]-actual[i]) > 1e-9 {
+			t.Errorf("Expected %v by %v, but was %v", expected, method, actual)
+			return
+		}
+	}
+}
+
+func TestNormalizeFeatureValues(t *testing.T) {
+	values := []float64{2, 4, 4, 10}
+
+	assertValues(t, "minmax", []float64{0, 0.25, 0.25, 1}, normalizeMinMax(values))
+	// Mean 5, standard deviation 3
+	assertValues(t, "zscore", []float64{-1, -1.0 / 3, -1.0 / 3, 5.0 / 3}, normalizeZScore(values))
+	assertValues(t, "rank", []float64{0, 0.5, 0.5, 1}, normalizeRank(values))
+
+	equal := []float64{3, 3}
+	assertValues(t, "minmax", []float64{0, 0}, normalizeMinMax(equal))
+	assertValues(t, "zscore", []float64{0, 0}, normalizeZScore(equal))
+	assertValues(t, "rank", []float64{0.5, 0.5}, normalizeRank(equal))
+	assertValues(t, "rank", []float64{0.5}, normalizeRank([]float64{7}))
+}
+
+// The degree of 1-1 is small compared to the bandwidth of 1-2, but counts twice
+func TestNormalizedScores(t *testing.T) {
+	as11, _ := addr.IAFromString("1-1")
+	as12, _ := addr.IAFromString("1-2")
+	as13, _ := addr.IAFromString("1-3")
+	as14, _ := addr.IAFromString("1-4")
+	connections := map[addr.IA][]addr.IA{as11: {as12, as13, as14}, as12: {}, as13: {}, as14: {}}
+
+	scores := func(normalization string) map[addr.IA]*speedCamCandidate {
+		config := Default()
+		config.ScoreNormalization = normalization
+		config.WeightDegree = 2
+		config.WeightActivity = 1
+		config.WeightCapacity = 0
+		config.WeightSuccess = 0
+		config.WeightBetweenness = 0
+		config.WeightPathCentrality = 0
+		graph := Load(connections, config)
+		graph.AddBandwidth(as11, time.Now(), time.Minute, 1000)
+		graph.AddBandwidth(as12, time.Now(), time.Minute, 1000000)
+		return Create(config).scoreCandidates(graph.Snapshot().nodes)
+	}
+
+	raw := scores("none")
+	if raw[as12].score != 1 || raw[as11].score > 0.01 {
+		t.Errorf("Expected the activity to dominate the raw scores, but was %v and %v", raw[as11].score,
+			raw[as12].score)
+	}
+
+	for _, normalization := range []string{"minmax", "zscore", "rank"} {
+		candidates := scores(normalization)
+		if candidates[as11].score != 1 || candidates[as12].score >= 1 {
+			t.Errorf("Expected 1-1 with the highest %v score, but was %v and %v", normalization,
+				candidates[as11].score, candidates[as12].score)
+		}
+	}
+
+	candidates := scores("minmax")
+	features := candidates[as12].features
+	if features.Degree != 0 || features.Activity != 1 || candidates[as12].raw.Activity != 1000000 {
+		t.Errorf("Expected the normalized features of 1-2, but was %v (raw %v)", features, candidates[as12].raw)
+	}
+}
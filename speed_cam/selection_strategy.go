@@ -0,0 +1,362 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/scionproto/scion/go/lib/addr"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// A usable AS with its normalized candidate score (0.0 - 1.0).
+type SelectionCandidate struct {
+	IsdAs addr.IA
+	Score float64
+	// All links of the AS in the graph, also those to ASes which are no candidates. A SpeedCam measures all of them,
+	// parallel links to the same neighbor are distinct links
+	Links []LinkKey
+}
+
+// Why a candidate was selected or not.
+type SelectionReason string
+
+const (
+	NotSelected SelectionReason = "none"
+	// The random draw was within the score
+	SelectedByChance SelectionReason = "probabilistic"
+	// Not enough candidates were selected otherwise, so the highest scores were added
+	SelectedByTopUp SelectionReason = "topup"
+	// One of the highest scores
+	SelectedByRank SelectionReason = "rank"
+	// Drawn by the weighted sampling
+	SelectedBySample SelectionReason = "weighted"
+	// Not selected for too many episodes
+	SelectedAsDue SelectionReason = "due"
+	// Covers the most valuable links not covered yet
+	SelectedByCoverage SelectionReason = "coverage"
+)
+
+// The decision of a strategy about a candidate.
+type SelectionDecision struct {
+	IsdAs    addr.IA
+	Selected bool
+	Reason   SelectionReason
+	// The random number drawn for the candidate, only valid if Drawn
+	Draw  float64
+	Drawn bool
+}
+
+// Selects the ASes the SpeedCams of an episode are started on.
+type SelectionStrategy interface {
+	// Selects the amount of candidates, or all of them if there are fewer. It is called once per episode with the
+	// candidates in a stable order, so the selection only depends on the seed. Returns the decisions in the order of
+	// the candidates.
+	Select(candidates []SelectionCandidate, count int) []SelectionDecision
+}
+
+// The decisions about the candidates while selecting.
+type selectionDecisions struct {
+	decisions []SelectionDecision
+	index     map[addr.IA]int
+	selected  int
+}
+
+func newSelectionDecisions(candidates []SelectionCandidate) *selectionDecisions {
+	decisions := &selectionDecisions{index: make(map[addr.IA]int, len(candidates))}
+	for i, v := range candidates {
+		decisions.decisions = append(decisions.decisions, SelectionDecision{IsdAs: v.IsdAs, Reason: NotSelected})
+		decisions.index[v.IsdAs] = i
+	}
+	return decisions
+}
+
+func (decisions *selectionDecisions) choose(isdAs addr.IA, reason SelectionReason) {
+	decision := &decisions.decisions[decisions.index[isdAs]]
+	if !decision.Selected {
+		decisions.selected++
+	}
+	decision.Selected = true
+	decision.Reason = reason
+}
+
+func (decisions *selectionDecisions) isSelected(isdAs addr.IA) bool {
+	return decisions.decisions[decisions.index[isdAs]].Selected
+}
+
+func (decisions *selectionDecisions) draw(isdAs addr.IA, draw float64) {
+	decision := &decisions.decisions[decisions.index[isdAs]]
+	decision.Draw = draw
+	decision.Drawn = true
+}
+
+// Selects the not yet selected candidates with the highest scores until the count is reached.
+func (decisions *selectionDecisions) topUp(candidates []SelectionCandidate, count int, reason SelectionReason) {
+	for _, v := range sortByScore(candidates) {
+		if decisions.selected >= count {
+			break
+		}
+		if !decisions.isSelected(v.IsdAs) {
+			decisions.choose(v.IsdAs, reason)
+		}
+	}
+}
+
+// The selected ASes of the decisions.
+func SelectedIsdAses(decisions []SelectionDecision) []addr.IA {
+	var selected []addr.IA
+	for _, v := range decisions {
+		if v.Selected {
+			selected = append(selected, v.IsdAs)
+		}
+	}
+	return selected
+}
+
+// Creates the strategy configured by the selection strategy. Random strategies draw from the seed of the config.
+func CreateSelectionStrategy(config *SpeedCamConfig) SelectionStrategy {
+	random := newRandom(config.Seed, randomStreamSelection)
+	switch config.SelectionStrategy {
+	case "probabilistic":
+		return &probabilisticSelection{random: random}
+	case "topk":
+		return &topKSelection{}
+	case "weighted":
+		return &weightedSelection{random: random}
+	case "roundrobin":
+		if config.SelectionCoverage == 0 {
+			MyLogger.Panicf("Coverage of the round-robin selection must be at least one episode!")
+		}
+		return &roundRobinSelection{coverage: int(config.SelectionCoverage), lastSelected: make(map[addr.IA]int)}
+	case "coverage":
+		return &coverageSelection{}
+	default:
+		MyLogger.Panicf("Unsupported selection strategy '%v'", config.SelectionStrategy)
+		return nil
+	}
+}
+
+// Selects a candidate with the chance of its score. Without enough selected candidates the highest scores are
+// selected additionally.
+type probabilisticSelection struct {
+	random *rand.Rand
+}
+
+func (strategy *probabilisticSelection) Select(candidates []SelectionCandidate, count int) []SelectionDecision {
+	decisions := newSelectionDecisions(candidates)
+	for _, v := range candidates {
+		if decisions.selected >= count {
+			break
+		}
+		// Is the speedCam selected?
+		draw := strategy.random.Float64()
+		decisions.draw(v.IsdAs, draw)
+		if draw <= v.Score {
+			decisions.choose(v.IsdAs, SelectedByChance)
+		}
+	}
+
+	// Are not enough speedCams selected -> select highest chance
+	decisions.topUp(candidates, count, SelectedByTopUp)
+	return decisions.decisions
+}
+
+// Selects the candidates with the highest scores.
+type topKSelection struct {
+}
+
+func (strategy *topKSelection) Select(candidates []SelectionCandidate, count int) []SelectionDecision {
+	decisions := newSelectionDecisions(candidates)
+	decisions.topUp(candidates, count, SelectedByRank)
+	return decisions.decisions
+}
+
+// Samples the candidates without replacement, each draw with the chance of the score among the remaining scores.
+// Candidates without a score are only selected if there are not enough other candidates.
+type weightedSelection struct {
+	random *rand.Rand
+}
+
+func (strategy *weightedSelection) Select(candidates []SelectionCandidate, count int) []SelectionDecision {
+	decisions := newSelectionDecisions(candidates)
+	// Algorithm of Efraimidis and Spirakis: the candidates with the highest random^(1/score) keys are selected
+	keys := make(map[addr.IA]float64, len(candidates))
+	for _, v := range candidates {
+		random := strategy.random.Float64()
+		decisions.draw(v.IsdAs, random)
+		if v.Score > 0 {
+			keys[v.IsdAs] = math.Pow(random, 1/v.Score)
+		} else {
+			// Below all keys of positive scores
+			keys[v.IsdAs] = -1 - random
+		}
+	}
+
+	sorted := make([]SelectionCandidate, len(candidates))
+	copy(sorted, candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return keys[sorted[i].IsdAs] > keys[sorted[j].IsdAs]
+	})
+
+	for _, v := range sorted {
+		if decisions.selected >= count {
+			break
+		}
+		decisions.choose(v.IsdAs, SelectedBySample)
+	}
+	return decisions.decisions
+}
+
+// Selects every candidate at least once per coverage episodes. Candidates not selected for the longest time are
+// selected first, the remaining SpeedCams go to the highest scores. If the count is too small to cover all
+// candidates in time, more candidates are selected.
+type roundRobinSelection struct {
+	coverage int
+	episode  int
+	// The episode a candidate was selected the last time
+	lastSelected map[addr.IA]int
+}
+
+func (strategy *roundRobinSelection) Select(candidates []SelectionCandidate, count int) []SelectionDecision {
+	strategy.episode++
+
+	current := make(map[addr.IA]bool, len(candidates))
+	for _, v := range candidates {
+		current[v.IsdAs] = true
+		// New candidates are due within the next coverage episodes
+		if _, exists := strategy.lastSelected[v.IsdAs]; !exists {
+			strategy.lastSelected[v.IsdAs] = strategy.episode - 1
+		}
+	}
+	for k := range strategy.lastSelected {
+		if !current[k] {
+			delete(strategy.lastSelected, k)
+		}
+	}
+
+	minimum := (len(candidates) + strategy.coverage - 1) / strategy.coverage
+	if count < minimum {
+		count = minimum
+	}
+
+	// Longest not selected first, ties by the highest score
+	sorted := sortByScore(candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strategy.lastSelected[sorted[i].IsdAs] < strategy.lastSelected[sorted[j].IsdAs]
+	})
+
+	decisions := newSelectionDecisions(candidates)
+	for _, v := range sorted {
+		if decisions.selected >= minimum {
+			break
+		}
+		decisions.choose(v.IsdAs, SelectedAsDue)
+	}
+	decisions.topUp(candidates, count, SelectedByTopUp)
+
+	for _, v := range SelectedIsdAses(decisions.decisions) {
+		strategy.lastSelected[v] = strategy.episode
+	}
+	return decisions.decisions
+}
+
+// Selects the candidates covering the most valuable links, as a SpeedCam measures all links of its AS. Adjacent
+// candidates share a link, so selecting both wastes a SpeedCam on it. The weight of a link is the average score of
+// its ends which are candidates. The weighted maximum coverage is approximated greedily: the candidate with the
+// highest weight of not yet covered links is selected next. Candidates without uncovered links are not selected,
+// even if the count is not reached.
+type coverageSelection struct {
+}
+
+func (strategy *coverageSelection) Select(candidates []SelectionCandidate, count int) []SelectionDecision {
+	scores := make(map[addr.IA]float64, len(candidates))
+	for _, v := range candidates {
+		scores[v.IsdAs] = v.Score
+	}
+	linkWeight := func(link LinkKey) float64 {
+		scoreA, existsA := scores[link.A]
+		scoreB, existsB := scores[link.B]
+		if existsA && existsB {
+			return (scoreA + scoreB) / 2
+		}
+		return scoreA + scoreB
+	}
+
+	covered := make(map[LinkKey]bool)
+	decisions := newSelectionDecisions(candidates)
+	for decisions.selected < count {
+		best := -1
+		bestWeight := 0.0
+		bestLinks := 0
+		for i, v := range candidates {
+			if decisions.isSelected(v.IsdAs) {
+				continue
+			}
+			weight := 0.0
+			links := 0
+			for _, link := range v.Links {
+				if !covered[link] {
+					weight += linkWeight(link)
+					links++
+				}
+			}
+			if links == 0 {
+				continue
+			}
+			if best < 0 || weight > bestWeight || weight == bestWeight && (links > bestLinks ||
+				links == bestLinks && v.Score > candidates[best].Score) {
+				best, bestWeight, bestLinks = i, weight, links
+			}
+		}
+		// Every link of the candidates is covered
+		if best < 0 {
+			break
+		}
+
+		candidate := candidates[best]
+		decisions.choose(candidate.IsdAs, SelectedByCoverage)
+		for _, link := range candidate.Links {
+			covered[link] = true
+		}
+	}
+	return decisions.decisions
+}
+
+// The amount of links measured by SpeedCams on the nodes. A link between two of the nodes counts once, parallel
+// links between the same ASes count each.
+func coveredLinks(nodes []networkNode, links map[LinkKey]*networkLink) int {
+	selected := make(map[addr.IA]bool, len(nodes))
+	for _, v := range nodes {
+		selected[v.IsdAs] = true
+	}
+	covered := 0
+	for k := range links {
+		if selected[k.A] || selected[k.B] {
+			covered++
+		}
+	}
+	return covered
+}
+
+// Copy of the candidates in descending order of their scores.
+func sortByScore(candidates []SelectionCandidate) []SelectionCandidate {
+	sorted := make([]SelectionCandidate, len(candidates))
+	copy(sorted, candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Score > sorted[j].Score
+	})
+	return sorted
+}
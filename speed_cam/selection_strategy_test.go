@@ -0,0 +1,329 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"fmt"
+	"github.com/scionproto/scion/go/lib/addr"
+	"testing"
+	"time"
+)
+
+func createSelectionCandidates(scores ...float64) []SelectionCandidate {
+	var candidates []SelectionCandidate
+	for i, v := range scores {
+		isdAs, _ := addr.IAFromString(fmt.Sprintf("1-%v", i+1))
+		candidates = append(candidates, SelectionCandidate{IsdAs: isdAs, Score: v})
+	}
+	return candidates
+}
+
+func TestTopKSelection(t *testing.T) {
+	config := Default()
+	config.SelectionStrategy = "topk"
+	candidates := createSelectionCandidates(0.2, 1.0, 0.5, 0.1)
+
+	selected := SelectedIsdAses(CreateSelectionStrategy(config).Select(candidates, 2))
+	if len(selected) != 2 || selected[0] != candidates[1].IsdAs || selected[1] != candidates[2].IsdAs {
+		t.Errorf("Expected 1-2 and 1-3, but was %v", selected)
+	}
+	if selected := SelectedIsdAses(CreateSelectionStrategy(config).Select(candidates, 10)); len(selected) != 4 {
+		t.Errorf("Expected all 4 candidates, but was %v", selected)
+	}
+}
+
+func TestProbabilisticSelection(t *testing.T) {
+	config := Default()
+	config.SelectionStrategy = "probabilistic"
+	// The candidate without a score is only selected to fill up
+	candidates := createSelectionCandidates(0, 1.0, 1.0)
+
+	selected := SelectedIsdAses(CreateSelectionStrategy(config).Select(candidates, 2))
+	if len(selected) != 2 || selected[0] != candidates[1].IsdAs || selected[1] != candidates[2].IsdAs {
+		t.Errorf("Expected 1-2 and 1-3, but was %v", selected)
+	}
+}
+
+func TestWeightedSelection(t *testing.T) {
+	config := Default()
+	config.SelectionStrategy = "weighted"
+	candidates := createSelectionCandidates(0.9, 0.1, 0)
+	strategy := CreateSelectionStrategy(config)
+
+	counts := make(map[addr.IA]int)
+	for i := 0; i < 1000; i++ {
+		selected := SelectedIsdAses(strategy.Select(candidates, 1))
+		if len(selected) != 1 {
+			t.Fatalf("Expected one candidate, but was %v", selected)
+		}
+		counts[selected[0]]++
+	}
+	if counts[candidates[0].IsdAs] < 800 || counts[candidates[1].IsdAs] == 0 || counts[candidates[2].IsdAs] != 0 {
+		t.Errorf("Expected the selection by the scores 0.9 and 0.1, but was %v", counts)
+	}
+
+	// Without replacement, the candidate without a score last
+	for i := 0; i < 100; i++ {
+		selected := SelectedIsdAses(strategy.Select(candidates, 2))
+		if len(selected) != 2 || selected[0] != candidates[0].IsdAs || selected[1] != candidates[1].IsdAs {
+			t.Fatalf("Expected 1-1 and 1-2, but was %v", selected)
+		}
+	}
+	if selected := SelectedIsdAses(strategy.Select(candidates, 3)); len(selected) != 3 {
+		t.Errorf("Expected all candidates, but was %v", selected)
+	}
+}
+
+func TestRoundRobinSelection(t *testing.T) {
+	config := Default()
+	config.SelectionStrategy = "roundrobin"
+	config.SelectionCoverage = 3
+	candidates := createSelectionCandidates(1.0, 0.9, 0.8, 0.1, 0.1, 0.1, 0.1)
+	strategy := CreateSelectionStrategy(config)
+
+	// One SpeedCam is too few to cover 7 ASes in 3 episodes
+	for round := 0; round < 3; round++ {
+		selected := make(map[addr.IA]bool)
+		for episode := 0; episode < 3; episode++ {
+			result := SelectedIsdAses(strategy.Select(candidates, 1))
+			if len(result) != 3 {
+				t.Fatalf("Expected 3 SpeedCams per episode, but was %v", result)
+			}
+			for _, v := range result {
+				selected[v] = true
+			}
+		}
+		if len(selected) != len(candidates) {
+			t.Errorf("Expected all candidates within 3 episodes, but was %v", selected)
+		}
+	}
+}
+
+// The strategy of the config is part of the result
+func TestSelectionStrategyInResult(t *testing.T) {
+	config := Default()
+	config.SelectionStrategy = "topk"
+	inspector := CreateEmptyGraph(config)
+
+	result := SerializableResult(inspector, nil, nil, time.Now(), time.Second)
+	if result.Config.SelectionStrategy != "topk" {
+		t.Errorf("Expected the strategy topk in the result, but was %v", result.Config.SelectionStrategy)
+	}
+}
+
+// Two runs with the same seed select the same SpeedCams, regardless of the map order
+func TestSeededSelection(t *testing.T) {
+	connections := make(map[addr.IA][]addr.IA)
+	for i := 1; i <= 20; i++ {
+		isdAs, _ := addr.IAFromString(fmt.Sprintf("1-%v", i))
+		neighbor, _ := addr.IAFromString(fmt.Sprintf("1-%v", i%20+1))
+		connections[isdAs] = []addr.IA{neighbor}
+	}
+
+	for _, strategy := range []string{"probabilistic", "weighted"} {
+		config := Default()
+		config.SelectionStrategy = strategy
+		config.Seed = 42
+		snapshot := Load(connections, config).Snapshot()
+		first := Create(config)
+		second := Create(config)
+
+		for episode := 0; episode < 5; episode++ {
+			a := first.SelectUsableSpeedCams(snapshot.nodes, snapshot.links)
+			b := second.SelectUsableSpeedCams(snapshot.nodes, snapshot.links)
+			if len(a) != len(b) {
+				t.Fatalf("Expected the same selection by %v, but was %v and %v", strategy, a, b)
+			}
+			for i := range a {
+				if a[i].IsdAs != b[i].IsdAs {
+					t.Errorf("Expected the same selection by %v, but was %v and %v", strategy, a, b)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestRandomSeed(t *testing.T) {
+	config := Default()
+	inspector := CreateEmptyGraph(config)
+	if inspector.config.Seed == 0 {
+		t.Error("Expected a seed chosen on start")
+	}
+	if config.Seed != 0 {
+		t.Errorf("Expected the config of the caller to stay unchanged, but its seed was %v", config.Seed)
+	}
+
+	config = Default()
+	config.Seed = 7
+	inspector = CreateEmptyGraph(config)
+	if inspector.config.Seed != 7 {
+		t.Errorf("Expected the configured seed 7, but was %v", inspector.config.Seed)
+	}
+}
+
+// Test topology: 1-1 <-> 1-2 <-> 1-3 <-> 1-4 <-> 1-5
+func TestCoverageSelection(t *testing.T) {
+	config := Default()
+	config.SelectionStrategy = "coverage"
+	candidates := createSelectionCandidates(1.0, 1.0, 1.0, 1.0, 1.0)
+	for i := range candidates {
+		if i > 0 {
+			candidates[i].Links = append(candidates[i].Links,
+				NewLinkKey(candidates[i].IsdAs, 0, candidates[i-1].IsdAs, 0))
+		}
+		if i < len(candidates)-1 {
+			candidates[i].Links = append(candidates[i].Links,
+				NewLinkKey(candidates[i].IsdAs, 0, candidates[i+1].IsdAs, 0))
+		}
+	}
+
+	// Adjacent ASes would share a link
+	selected := SelectedIsdAses(CreateSelectionStrategy(config).Select(candidates, 2))
+	if len(selected) != 2 || selected[0] != candidates[1].IsdAs || selected[1] != candidates[3].IsdAs {
+		t.Errorf("Expected 1-2 and 1-4, but was %v", selected)
+	}
+
+	// Links to the valuable 1-5 are worth more
+	candidates[4].Score = 3.0
+	selected = SelectedIsdAses(CreateSelectionStrategy(config).Select(candidates, 1))
+	if len(selected) != 1 || selected[0] != candidates[3].IsdAs {
+		t.Errorf("Expected 1-4, but was %v", selected)
+	}
+
+	// Only SpeedCams covering further links are selected
+	selected = SelectedIsdAses(CreateSelectionStrategy(config).Select(candidates, 5))
+	if len(selected) != 2 {
+		t.Errorf("Expected 1-2 and 1-4 covering all links, but was %v", selected)
+	}
+}
+
+func TestCoveredLinks(t *testing.T) {
+	as11, _ := addr.IAFromString("1-1")
+	as12, _ := addr.IAFromString("1-2")
+	as13, _ := addr.IAFromString("1-3")
+	graph := Load(map[addr.IA][]addr.IA{as11: {as12}, as12: {as13}, as13: {}}, Default())
+	snapshot := graph.Snapshot()
+	nodes := snapshot.nodes
+
+	if covered := coveredLinks([]networkNode{nodes[as11], nodes[as12]}, snapshot.links); covered != 2 {
+		t.Errorf("Expected 2 covered links, but was %v", covered)
+	}
+	if covered := coveredLinks([]networkNode{nodes[as11], nodes[as13]}, snapshot.links); covered != 2 {
+		t.Errorf("Expected 2 covered links, but was %v", covered)
+	}
+}
+
+// Test topology: 1-1 <-> 1-2 over one link, 1-2 <-> 1-3 over the interfaces 2/1 and 3/2
+func TestCoverageParallelLinks(t *testing.T) {
+	as11, _ := addr.IAFromString("1-1")
+	as12, _ := addr.IAFromString("1-2")
+	as13, _ := addr.IAFromString("1-3")
+	graph := Load(map[addr.IA][]addr.IA{as11: {}, as12: {}, as13: {}}, Default())
+	graph.ConnectInterfaces(as11, 1, as12, 1)
+	graph.ConnectInterfaces(as12, 2, as13, 1)
+	graph.ConnectInterfaces(as12, 3, as13, 2)
+	snapshot := graph.Snapshot()
+
+	if covered := coveredLinks([]networkNode{snapshot.nodes[as13]}, snapshot.links); covered != 2 {
+		t.Errorf("Expected the 2 parallel links covered, but was %v", covered)
+	}
+	if covered := coveredLinks([]networkNode{snapshot.nodes[as12]}, snapshot.links); covered != 3 {
+		t.Errorf("Expected 3 covered links, but was %v", covered)
+	}
+
+	// 1-3 covers more links than 1-1
+	config := Default()
+	config.SelectionStrategy = "coverage"
+	config.ScaleType = "const"
+	config.ScaleParam = 1
+	nodes := map[addr.IA]networkNode{as11: snapshot.nodes[as11], as13: snapshot.nodes[as13]}
+	selected := Create(config).SelectUsableSpeedCams(nodes, snapshot.links)
+	if len(selected) != 1 || selected[0].IsdAs != as13 {
+		t.Errorf("Expected 1-3 with the parallel links selected, but was %v", selected)
+	}
+}
+
+func TestSelectionDecisions(t *testing.T) {
+	config := Default()
+	config.SelectionStrategy = "probabilistic"
+	candidates := createSelectionCandidates(0, 0)
+
+	// Without a score only the top-up selects
+	decisions := CreateSelectionStrategy(config).Select(candidates, 1)
+	if len(decisions) != 2 || !decisions[0].Selected || decisions[0].Reason != SelectedByTopUp ||
+		decisions[1].Selected || decisions[1].Reason != NotSelected {
+		t.Errorf("Expected 1-1 selected by top-up, but was %v", decisions)
+	}
+	for _, v := range decisions {
+		if !v.Drawn || v.Draw < 0 || v.Draw >= 1 {
+			t.Errorf("Expected a draw of %v, but was %v", v.IsdAs, v)
+		}
+	}
+
+	config.SelectionStrategy = "topk"
+	decisions = CreateSelectionStrategy(config).Select(createSelectionCandidates(0.5, 1.0), 1)
+	if decisions[0].Selected || !decisions[1].Selected || decisions[1].Reason != SelectedByRank || decisions[1].Drawn {
+		t.Errorf("Expected 1-2 selected by rank without a draw, but was %v", decisions)
+	}
+}
+
+// Test topology: 1-1 <-> 1-2 <-> 1-3, scored by the degree only
+func TestSelectAndExplain(t *testing.T) {
+	as11, _ := addr.IAFromString("1-1")
+	as12, _ := addr.IAFromString("1-2")
+	as13, _ := addr.IAFromString("1-3")
+	config := Default()
+	config.SelectionStrategy = "topk"
+	config.ScaleType = "const"
+	config.ScaleParam = 1
+	config.ScoreNormalization = "minmax"
+	config.WeightDegree = 2
+	config.WeightCapacity = 0
+	config.WeightActivity = 0
+	config.WeightSuccess = 0
+	config.WeightBetweenness = 0
+	config.WeightPathCentrality = 0
+	graph := Load(map[addr.IA][]addr.IA{as11: {as12}, as12: {as13}, as13: {}}, config)
+
+	snapshot := graph.Snapshot()
+	selected, candidates := Create(config).SelectAndExplain(snapshot.nodes, snapshot.links)
+	if len(selected) != 1 || selected[0].IsdAs != as12 || len(candidates) != 3 {
+		t.Fatalf("Expected 1-2 selected of 3 candidates, but was %v of %v", selected, candidates)
+	}
+	candidate := candidates[as12]
+	if !candidate.Selected || candidate.Reason != SelectedByRank || candidate.Score != 1 {
+		t.Errorf("Expected 1-2 selected by rank with score 1, but was %+v", candidate)
+	}
+	if candidate.Raw.Degree != 2 || candidate.Normalized.Degree != 1 || candidate.Weighted.Degree != 2 {
+		t.Errorf("Expected the degree 2, normalized 1 and weighted 2, but was %+v", candidate)
+	}
+	if other := candidates[as11]; other.Selected || other.Reason != NotSelected || other.Score != 0 {
+		t.Errorf("Expected 1-1 not selected with score 0, but was %+v", other)
+	}
+
+	// The result uses the scores of the selector, ASes which are no candidates stay unscored
+	inspector := CreateWithGraph(config, graph)
+	result := SerializableResult(inspector, nil, candidates, time.Now(), time.Second)
+	if result.Graph[as12].CandidateScore != 1 || result.Graph[as12].Features.Degree != 1 || len(result.Candidates) != 3 {
+		t.Errorf("Expected the score 1 of the selector, but was %+v", result.Graph[as12])
+	}
+	delete(candidates, as12)
+	result = SerializableResult(inspector, nil, candidates, time.Now(), time.Second)
+	if result.Graph[as12].CandidateScore != 0 || result.Graph[as12].Features.Degree != 0 {
+		t.Errorf("Expected no score for a non candidate, but was %+v", result.Graph[as12])
+	}
+}
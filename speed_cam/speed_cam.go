@@ -16,12 +16,12 @@
 package speed_cam
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
-	"github.com/c2h5oh/datasize"
 	"github.com/scionproto/scion/go/lib/addr"
-	"strconv"
+	"math"
+	"sort"
 	"strings"
 	"time"
 )
@@ -29,14 +29,34 @@ import (
 type SpeedCam struct {
 	isdAs    addr.IA
 	duration time.Duration
-	start    time.Time
+	// An adaptive measurement continues after duration till maxDuration while the rates are varying
+	maxDuration time.Duration
+	// Coefficient of variation (standard deviation / mean) of the rates above which the rates are varying
+	variationThreshold float64
+	start              time.Time
+	// Shared with the other SpeedCams of an inspection
+	scheduler *PollScheduler
 }
 
+// Creates a SpeedCam measuring for a fixed duration. Its own scheduler has no jitter and draws no random numbers, an
+// inspector shares its scheduler seeded by the config instead.
 func CreateSpeedCam(isdAs addr.IA, duration time.Duration) *SpeedCam {
-	return &SpeedCam{isdAs: isdAs, duration: duration}
+	return &SpeedCam{isdAs: isdAs, duration: duration, maxDuration: duration,
+		scheduler: CreatePollScheduler(0, 0, 0, 0)}
 }
 
-func (cam *SpeedCam) Measure(measurementPoints []PrometheusClientInfo, pollInterval time.Duration) map[addr.IA][]SpeedCamResult {
+// Creates a SpeedCam measuring at least for minDuration and continuing till maxDuration while the coefficient of
+// variation of the observed rates stays above the threshold.
+func CreateAdaptiveSpeedCam(isdAs addr.IA, minDuration time.Duration, maxDuration time.Duration,
+	variationThreshold float64) *SpeedCam {
+	return &SpeedCam{isdAs: isdAs, duration: minDuration, maxDuration: maxDuration,
+		variationThreshold: variationThreshold, scheduler: CreatePollScheduler(0, 0, 0, 0)}
+}
+
+// Measures the links of the measurement points. When the context is cancelled, the measurement stops early and the
+// rates polled so far are returned. The statistics of each link's window are available via Statistics.
+func (cam *SpeedCam) Measure(ctx context.Context, measurementPoints []PrometheusClientInfo,
+	pollInterval time.Duration) map[LinkKey]SpeedCamResults {
 
 	cam.start = time.Now()
 
@@ -44,26 +64,27 @@ func (cam *SpeedCam) Measure(measurementPoints []PrometheusClientInfo, pollInter
 	defer close(resultChannel)
 
 	for _, v := range measurementPoints {
-		go cam.measureData(v, pollInterval, resultChannel)
+		go cam.measureData(ctx, v, pollInterval, resultChannel)
 	}
 
-	resultMap := make(map[addr.IA][]SpeedCamResult)
+	// Parallel links to the same neighbor are measured separately
+	resultMap := make(map[LinkKey]SpeedCamResults)
 	for i := 0; i < len(measurementPoints); i++ {
 		result := <-resultChannel
 		if result.err != nil {
 			MyLogger.Criticalf("error: %v\n", result.err)
 			continue
 		}
-		resultsPerBr := result.results
-		brId := resultsPerBr[0].Neighbor
-		resultMap[brId] = resultsPerBr
+		resultsPerLink := result.results
+		resultMap[resultsPerLink[0].LinkKey()] = resultsPerLink
 	}
 	return resultMap
 }
 
-func (cam *SpeedCam) measureData(measurementPoint PrometheusClientInfo, pollInterval time.Duration, c chan Result) {
+func (cam *SpeedCam) measureData(ctx context.Context, measurementPoint PrometheusClientInfo,
+	pollInterval time.Duration, c chan Result) {
 
-	results, err := collectData(cam, measurementPoint, pollInterval)
+	results, err := collectData(ctx, cam, measurementPoint, pollInterval)
 	if err != nil {
 		c <- Result{results: results, err: err}
 		return
@@ -80,104 +101,362 @@ func differentiateResults(results []SpeedCamResult) Result {
 		return Result{results: results, err: err}
 	}
 
-	diffResults := make([]SpeedCamResult, size-1)
+	diffResults := make([]SpeedCamResult, 0, size-1)
 
 	for i := 0; i < size-1; i++ {
-		diffResults[i] = differentiateResult(results[i], results[i+1])
+		// Polls without time in between have no rate
+		if !results[i+1].Timestamp.After(results[i].Timestamp) {
+			MyLogger.Warningf("Skip sample without duration at %v", results[i].Timestamp)
+			continue
+		}
+		diffResults = append(diffResults, differentiateResult(results[i], results[i+1]))
+	}
+	if len(diffResults) == 0 {
+		err := errors.New("No sample with a duration to differentiate")
+		return Result{results: diffResults, err: err}
 	}
 	return Result{results: diffResults, err: nil}
 }
 
 func differentiateResult(resultStart SpeedCamResult, resultEnd SpeedCamResult) SpeedCamResult {
 
-	result := SpeedCamResult{Neighbor: resultStart.Neighbor, Source: resultStart.Source}
-	// Prevent underflow
-	output := datasize.B
-	if resultStart.BandwidthOut > resultEnd.BandwidthOut {
-		output = 0
-	} else {
-		output = resultEnd.BandwidthOut - resultStart.BandwidthOut
-	}
-
-	// Prevent underflow
-	input := datasize.B
-	if resultStart.BandwidthIn > resultEnd.BandwidthIn {
-		input = 0
-	} else {
-		input = resultEnd.BandwidthIn - resultStart.BandwidthIn
+	result := SpeedCamResult{Neighbor: resultStart.Neighbor, Source: resultStart.Source, IfId: resultStart.IfId,
+		BrId: resultStart.BrId}
+	result.Reset = isReset(resultStart, resultEnd)
+	if result.Reset {
+		MyLogger.Warningf("Counter reset of BR between %v and %v detected. Link: %v<->%v", resultStart.Timestamp,
+			resultEnd.Timestamp, result.Source, result.Neighbor)
 	}
 
 	duration := resultEnd.Timestamp.Sub(resultStart.Timestamp)
-	unixTime := (resultEnd.Timestamp.Unix() + resultStart.Timestamp.Unix()) / 2
-	timeStamp := time.Unix(unixTime, 0)
-	result.BandwidthOut = output / datasize.ByteSize(duration.Seconds())
-	result.BandwidthIn = input / datasize.ByteSize(duration.Seconds())
-	result.Timestamp = timeStamp
+	seconds := duration.Seconds()
+	// A restart resets all counters, otherwise every counter is checked on its own (same as Prometheus' rate())
+	reset := isRestart(resultStart, resultEnd)
+	result.BandwidthOut = counterDiff(resultStart.BandwidthOut, resultEnd.BandwidthOut, reset) / seconds
+	result.BandwidthIn = counterDiff(resultStart.BandwidthIn, resultEnd.BandwidthIn, reset) / seconds
+	result.PacketsOut = counterDiff(resultStart.PacketsOut, resultEnd.PacketsOut, reset) / seconds
+	result.PacketsIn = counterDiff(resultStart.PacketsIn, resultEnd.PacketsIn, reset) / seconds
+	result.DroppedIn = counterDiff(resultStart.DroppedIn, resultEnd.DroppedIn, reset) / seconds
+	result.ReadErrorsIn = counterDiff(resultStart.ReadErrorsIn, resultEnd.ReadErrorsIn, reset) / seconds
+	result.WriteErrorsOut = counterDiff(resultStart.WriteErrorsOut, resultEnd.WriteErrorsOut, reset) / seconds
+	// Packet sizes are counted, not rated
+	result.PacketSizesIn = histogramDiff(resultStart.PacketSizesIn, resultEnd.PacketSizesIn, reset)
+	result.PacketSizesOut = histogramDiff(resultStart.PacketSizesOut, resultEnd.PacketSizesOut, reset)
+	// The rate is located in the middle of the sample
+	result.Timestamp = resultStart.Timestamp.Add(duration / 2)
+	result.Duration = duration
+	result.ProcessStart = resultEnd.ProcessStart
 
 	return result
 }
 
-func collectData(cam *SpeedCam, measurementPoint PrometheusClientInfo, pollInterval time.Duration) ([]SpeedCamResult, error) {
+// Checks if the BR restarted or its counters were reset between two polls. A restart is detected by a changed
+// process start time, a reset by any counter going backwards.
+func isReset(resultStart SpeedCamResult, resultEnd SpeedCamResult) bool {
+	if isRestart(resultStart, resultEnd) {
+		return true
+	}
+	return resultStart.BandwidthIn > resultEnd.BandwidthIn ||
+		resultStart.BandwidthOut > resultEnd.BandwidthOut ||
+		resultStart.PacketsIn > resultEnd.PacketsIn ||
+		resultStart.PacketsOut > resultEnd.PacketsOut ||
+		resultStart.DroppedIn > resultEnd.DroppedIn ||
+		resultStart.ReadErrorsIn > resultEnd.ReadErrorsIn ||
+		resultStart.WriteErrorsOut > resultEnd.WriteErrorsOut ||
+		resultStart.PacketSizesIn.decreased(resultEnd.PacketSizesIn) ||
+		resultStart.PacketSizesOut.decreased(resultEnd.PacketSizesOut)
+}
+
+// Checks if the BR process restarted between two polls, which resets all of its counters.
+func isRestart(resultStart SpeedCamResult, resultEnd SpeedCamResult) bool {
+	return !resultStart.ProcessStart.IsZero() && !resultStart.ProcessStart.Equal(resultEnd.ProcessStart)
+}
+
+// The increase of a counter between two polls. After a reset the counter started again from zero, so its current
+// value is the increase (same as Prometheus' rate()).
+func counterDiff(start float64, end float64, reset bool) float64 {
+	if reset || start > end {
+		return end
+	}
+	return end - start
+}
+
+func collectData(ctx context.Context, cam *SpeedCam, measurementPoint PrometheusClientInfo,
+	pollInterval time.Duration) ([]SpeedCamResult, error) {
 	end := cam.start.Add(cam.duration)
+	maxEnd := cam.start.Add(cam.maxDuration)
 	results := make([]SpeedCamResult, 0)
 	var err error = nil
-	for {
-		url := measurementPoint.URL()
 
-		result := SpeedCamResult{Timestamp: time.Now(), BandwidthIn: 0, BandwidthOut: 0, Source: cam.isdAs, Neighbor: measurementPoint.TargetIsdAs}
-		pollErr := cam.pollData(url, &result)
+	// Spread the polls of the SpeedCams
+	select {
+	case <-ctx.Done():
+		return results, nil
+	case <-time.After(cam.scheduler.StartDelay()):
+	}
 
+	for {
+		result := SpeedCamResult{Source: cam.isdAs, Neighbor: measurementPoint.TargetIsdAs,
+			IfId: measurementPoint.IfId, BrId: measurementPoint.BrId}
+		pollErr := cam.pollData(ctx, measurementPoint, &result)
+
+		// A cancelled poll is no error, the results so far are kept
+		if ctx.Err() != nil {
+			MyLogger.Debugf("Measurement of speed cam %v cancelled after %v polls", cam.isdAs, len(results))
+			break
+		}
 		if pollErr != nil {
-			err = errors.New(fmt.Sprintf("error polling data. speed cam: %v, url: %v\n", cam.isdAs, url))
+			err = errors.New(fmt.Sprintf("error polling data. speed cam: %v, url: %v, err: %v\n", cam.isdAs,
+				measurementPoint.URL(), pollErr))
 			break
 		}
 
 		results = append(results, result)
-		if time.Now().After(end) {
+		now := time.Now()
+		if now.After(maxEnd) || (now.After(end) && !cam.isVarying(results)) {
 			break
 		}
 
-		time.Sleep(pollInterval)
+		select {
+		case <-ctx.Done():
+			return results, nil
+		case <-time.After(pollInterval):
+		}
 	}
 
 	return results, err
 }
 
-func (cam *SpeedCam) pollData(prometheusUrl string, result *SpeedCamResult) error {
+// Checks if an adaptive measurement should continue, because the byte rates of the polled results are varying.
+func (cam *SpeedCam) isVarying(results []SpeedCamResult) bool {
+	// At least two rates are necessary for a variation
+	if cam.maxDuration <= cam.duration || len(results) < 3 {
+		return false
+	}
+
+	rates := make([]float64, 0, len(results)-1)
+	for i := 0; i < len(results)-1; i++ {
+		seconds := results[i+1].Timestamp.Sub(results[i].Timestamp).Seconds()
+		if seconds <= 0 {
+			continue
+		}
+		reset := isRestart(results[i], results[i+1])
+		bytes := counterDiff(results[i].BandwidthIn, results[i+1].BandwidthIn, reset) +
+			counterDiff(results[i].BandwidthOut, results[i+1].BandwidthOut, reset)
+		rates = append(rates, bytes/seconds)
+	}
+
+	return coefficientOfVariation(rates) > cam.variationThreshold
+}
 
-	readBytes, err := FetchData(prometheusUrl + "/metrics")
+func coefficientOfVariation(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if mean == 0 {
+		return 0
+	}
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+	return math.Sqrt(variance) / mean
+}
+
+func (cam *SpeedCam) pollData(ctx context.Context, measurementPoint PrometheusClientInfo, result *SpeedCamResult) error {
+
+	readBytes, timestamp, err := cam.scheduler.Poll(ctx, measurementPoint.Ip, measurementPoint.URL()+"/metrics")
 	if err != nil {
-		MyLogger.Criticalf("error polling data, err: %v\n", err)
+		if ctx.Err() == nil {
+			MyLogger.Criticalf("error polling data, err: %v\n", err)
+		}
 		return err
 	}
-	scanner := bufio.NewScanner(strings.NewReader(string(readBytes)))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "border_input_bytes_total") {
-			v := parseValue(line)
-			result.BandwidthIn = datasize.ByteSize(v)
-		} else if strings.HasPrefix(line, "border_output_bytes_total") {
-			v := parseValue(line)
-			result.BandwidthOut = datasize.ByteSize(v)
-		}
+	// A shared poll may have started before this SpeedCam asked for it
+	result.Timestamp = timestamp
+	metrics, err := ParsePrometheusMetrics(readBytes)
+	if err != nil {
+		MyLogger.Criticalf("error parsing metrics, err: %v\n", err)
+		return err
+	}
+
+	sock, err := interfaceSocket(metrics, measurementPoint)
+	if err != nil {
+		return err
+	}
+	labels := map[string]string{"sock": sock}
+
+	input, exists := metrics.Sample("border_input_bytes_total", labels)
+	if !exists {
+		return errors.New(fmt.Sprintf("no border_input_bytes_total for sock %v", sock))
+	}
+	output, exists := metrics.Sample("border_output_bytes_total", labels)
+	if !exists {
+		return errors.New(fmt.Sprintf("no border_output_bytes_total for sock %v", sock))
+	}
+	result.BandwidthIn = input.Value
+	result.BandwidthOut = output.Value
+
+	// Optional counters, not every BR version exports them
+	result.PacketsIn = counterValue(metrics, "border_input_pkts_total", labels)
+	result.PacketsOut = counterValue(metrics, "border_output_pkts_total", labels)
+	result.DroppedIn = counterValue(metrics, "border_input_overflow_packets_total", labels)
+	result.ReadErrorsIn = counterValue(metrics, "border_input_read_errors_total", labels)
+	result.WriteErrorsOut = counterValue(metrics, "border_output_write_errors_total", labels)
+	result.PacketSizesIn = packetSizeHistogram(metrics, "border_input_pkt_size_bytes", labels)
+	result.PacketSizesOut = packetSizeHistogram(metrics, "border_output_pkt_size_bytes", labels)
+
+	processStart, exists := metrics.Sample("process_start_time_seconds", nil)
+	if exists {
+		result.ProcessStart = time.Unix(0, int64(processStart.Value*float64(time.Second)))
 	}
 
 	return nil
 }
 
-func parseValue(line string) uint64 {
-	i := strings.LastIndex(line, " ") + 1
-	numberString := line[i:]
-	v, _ := strconv.ParseFloat(numberString, 64)
-	return uint64(v)
+func counterValue(metrics PrometheusMetrics, name string, labels map[string]string) float64 {
+	sample, exists := metrics.Sample(name, labels)
+	if !exists {
+		return 0
+	}
+	return sample.Value
+}
+
+// Determines the sock label of the BR interface facing the target AS. If the interface id is unknown, the only
+// existing interface of the BR is used.
+func interfaceSocket(metrics PrometheusMetrics, measurementPoint PrometheusClientInfo) (string, error) {
+	if measurementPoint.IfId != 0 {
+		return measurementPoint.Socket(), nil
+	}
+
+	var interfaces []string
+	for _, sock := range metrics.LabelValues("border_input_bytes_total", "sock") {
+		if strings.HasPrefix(sock, "intf:") {
+			interfaces = append(interfaces, sock)
+		}
+	}
+	if len(interfaces) != 1 {
+		return "", errors.New(fmt.Sprintf("cannot determine interface to %v of BR %v, candidates: %v",
+			measurementPoint.TargetIsdAs, measurementPoint.BrId, interfaces))
+	}
+	return interfaces[0], nil
 }
 
+// A measurement of a link between the SpeedCam and a neighbor. Before differentiation the values are the absolute
+// counters of the BR, afterwards they are rates per second.
 type SpeedCamResult struct {
-	Timestamp    time.Time
-	BandwidthIn  datasize.ByteSize
-	BandwidthOut datasize.ByteSize
-	Source       addr.IA
-	Neighbor     addr.IA
+	Timestamp time.Time
+	// The time span of the rates, zero before differentiation
+	Duration time.Duration
+	// Bytes
+	BandwidthIn  float64
+	BandwidthOut float64
+	PacketsIn    float64
+	PacketsOut   float64
+	// Input packets dropped by the kernel due to a receive buffer overflow
+	DroppedIn      float64
+	ReadErrorsIn   float64
+	WriteErrorsOut float64
+	Source         addr.IA
+	Neighbor       addr.IA
+	// The measured interface of the source facing the neighbor and its BR. Zero and empty if unknown
+	IfId int
+	BrId string
+	// The start time of the BR process, zero if unknown
+	ProcessStart time.Time
+	// If true, the BR restarted or its counters were reset during this sample
+	Reset bool
+	// Sizes of the packets of this sample, empty if the BR exports no histograms
+	PacketSizesIn  PacketSizeHistogram
+	PacketSizesOut PacketSizeHistogram
+}
+
+// The key of the measured link. The interface of the neighbor is unknown.
+func (result *SpeedCamResult) LinkKey() LinkKey {
+	return NewLinkKey(result.Source, result.IfId, result.Neighbor, 0)
+}
+
+// Sum of socket read and write errors
+func (result *SpeedCamResult) Errors() float64 {
+	return result.ReadErrorsIn + result.WriteErrorsOut
+}
+
+// Statistics about the rates of a link over the measurement window of a SpeedCam
+type SpeedCamStatistics struct {
+	Samples int
+	// The time span covered by the samples
+	Window       time.Duration
+	Resets       int
+	BandwidthIn  RateStatistics
+	BandwidthOut RateStatistics
+	PacketsIn    RateStatistics
+	PacketsOut   RateStatistics
+	DroppedIn    RateStatistics
+	// Sizes of all packets of the window
+	PacketSizesIn  PacketSizeHistogram
+	PacketSizesOut PacketSizeHistogram
+}
+
+// Distribution of a rate over the samples of a measurement window
+type RateStatistics struct {
+	Min  float64
+	Max  float64
+	Mean float64
+	// 95th percentile (nearest rank)
+	P95 float64
+}
+
+// The differentiated results of a link over the measurement window of a SpeedCam, oldest first.
+type SpeedCamResults []SpeedCamResult
+
+// Calculates the statistics of the rates over the window, so short bursts are not averaged away.
+func (results SpeedCamResults) Statistics() SpeedCamStatistics {
+	statistics := SpeedCamStatistics{Samples: len(results)}
+	for _, v := range results {
+		statistics.Window += v.Duration
+		if v.Reset {
+			statistics.Resets++
+		}
+		statistics.PacketSizesIn.Add(v.PacketSizesIn)
+		statistics.PacketSizesOut.Add(v.PacketSizesOut)
+	}
+
+	statistics.BandwidthIn = rateStatistics(results, func(r SpeedCamResult) float64 { return r.BandwidthIn })
+	statistics.BandwidthOut = rateStatistics(results, func(r SpeedCamResult) float64 { return r.BandwidthOut })
+	statistics.PacketsIn = rateStatistics(results, func(r SpeedCamResult) float64 { return r.PacketsIn })
+	statistics.PacketsOut = rateStatistics(results, func(r SpeedCamResult) float64 { return r.PacketsOut })
+	statistics.DroppedIn = rateStatistics(results, func(r SpeedCamResult) float64 { return r.DroppedIn })
+	return statistics
+}
+
+func rateStatistics(results []SpeedCamResult, rate func(SpeedCamResult) float64) RateStatistics {
+	statistics := RateStatistics{}
+	if len(results) == 0 {
+		return statistics
+	}
+
+	values := make([]float64, len(results))
+	sum := 0.0
+	for i, v := range results {
+		values[i] = rate(v)
+		sum += values[i]
+	}
+	sort.Float64s(values)
+
+	statistics.Min = values[0]
+	statistics.Max = values[len(values)-1]
+	statistics.Mean = sum / float64(len(values))
+	rank := int(math.Ceil(0.95*float64(len(values)))) - 1
+	statistics.P95 = values[rank]
+	return statistics
 }
 
 type Result struct {
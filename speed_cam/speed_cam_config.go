@@ -16,6 +16,7 @@
 package speed_cam
 
 import (
+	"errors"
 	"fmt"
 	"math"
 )
@@ -32,6 +33,10 @@ type SpeedCamConfig struct {
 	WeightSuccess float64
 	// The importance for node's activity rate to be selected
 	WeightActivity float64
+	// The importance for node's betweenness centrality to be selected
+	WeightBetweenness float64
+	// The importance for node's share of the paths of the path requests to be selected
+	WeightPathCentrality float64
 	// Additional or fewer SpeedCams to be selected
 	SpeedCamDiff int
 	// If enabled, there will be additional console output
@@ -45,12 +50,74 @@ type SpeedCamConfig struct {
 	// The factor for the scale. For 'log' this is the base for the logarithmic, for 'linear' it is the factor and
 	// for 'const' it is the constant itself
 	ScaleParam float64
+	// How the SpeedCams are selected among the candidates. Currently supported are 'probabilistic', 'topk',
+	// 'weighted', 'roundrobin' and 'coverage'
+	SelectionStrategy string
+	// Episodes within every candidate is selected at least once by the 'roundrobin' strategy
+	SelectionCoverage uint
+	// How the features of the candidate score are normalized across the candidates before the weights are applied.
+	// Currently supported are 'minmax', 'zscore', 'rank' and 'none'
+	ScoreNormalization string
+	// Seed of the random selection and wait times. Zero stands for a seed chosen on start, which is written to the
+	// results like the rest of the config
+	Seed int64
 	// The strategy to wait till next inspection. Currently supported are 'fixed','random','experience'
 	IntervalStrategy string
 	// Seconds to wait at minimum till next inspection.
 	IntervalWaitMin uint
 	// Seconds to wait at maximum till next inspection.
 	IntervalWaitMax uint
+	// The rule to detect a congestion in the results of a SpeedCam. Currently supported are 'utilization',
+	// 'overflow', 'spike' and 'any'
+	DetectionStrategy string
+	// Share of the capacity (0.0 - 1.0) a link must use to be detected as congested
+	DetectionUtilization float64
+	// Dropped input packets per second a link must exceed to be detected as congested
+	DetectionOverflow uint64
+	// Factor the bandwidth must exceed the average bandwidth of previous episodes to be detected as congested
+	DetectionSpikeFactor float64
+	// If it is a non empty string, link capacities are loaded from this JSON file
+	CapacityFile string
+	// If it is a non empty string, link capacities are loaded from the topology.json files in this dir (e.g. SCION's gen dir)
+	TopologyDir string
+	// If enabled, the graph is bootstrapped with the ASes and links of the topology.json files in the topology dir
+	TopologyBootstrap bool
+	// How long a SpeedCam measures. Currently supported are 'fixed' and 'adaptive'
+	MeasurementStrategy string
+	// Seconds a SpeedCam measures. Minimum for the 'adaptive' strategy
+	MeasurementDuration uint
+	// Seconds a SpeedCam measures at maximum for the 'adaptive' strategy
+	MeasurementDurationMax uint
+	// Coefficient of variation of the rates above which an 'adaptive' measurement continues
+	MeasurementVariation float64
+	// Seconds between two polls of a BR
+	PollInterval uint
+	// Maximum of concurrent BR polls. Zero or negative stands for no limit
+	PollConcurrency int
+	// Maximum of concurrent polls per BR host. Zero or negative stands for no limit
+	PollHostConcurrency int
+	// Milliseconds the first poll of a SpeedCam is randomly delayed at maximum. Polls of the same BR started within
+	// this time are shared
+	PollJitter uint
+	// Minutes an AS or link stays in the graph without being seen in a path request or the BR information. Zero
+	// stands for infinity
+	GraphTTL uint
+	// If it is a non empty string, the graph and the episode history are checkpointed to this file and restored from
+	// it on startup
+	StateFile string
+	// Seconds between two checkpoints to the state file
+	StateInterval uint
+	// Seconds between two fetches of the path requests
+	FetchPathRequestInterval uint
+	// Seconds between two fetches of the BR information
+	FetchBrInfoInterval uint
+	// Seconds to wait before retrying a failed fetch, at least one. It doubles with every further failure in a row
+	FetchBackoff uint
+	// Seconds to wait at maximum before retrying a failed fetch
+	FetchBackoffMax uint
+	// If it is a non empty string, the inspector listens on this address (e.g. ':8090') for pushed path requests and
+	// BR information
+	IngestAddress string
 }
 
 // Default values for the algorithm.
@@ -61,25 +128,93 @@ func Default() *SpeedCamConfig {
 	config.WeightCapacity = 1.0
 	config.WeightSuccess = 1.0
 	config.WeightActivity = 1.0
+	config.WeightBetweenness = 1.0
+	config.WeightPathCentrality = 1.0
 	config.SpeedCamDiff = 0
 	config.Verbose = true
 	config.ResultDir = ""
 	config.MaxResults = -1
 	config.ScaleType = "linear"
 	config.ScaleParam = 0.2
+	config.SelectionStrategy = "probabilistic"
+	config.SelectionCoverage = 6
+	config.ScoreNormalization = "minmax"
+	config.Seed = 0
 	config.IntervalStrategy = "fixed"
 	config.IntervalWaitMin = 10   // 10 seconds
 	config.IntervalWaitMax = 3600 // 1 hour
+	config.DetectionStrategy = "any"
+	config.DetectionUtilization = 0.8
+	config.DetectionOverflow = 10
+	config.DetectionSpikeFactor = 2.0
+	config.CapacityFile = ""
+	config.TopologyDir = ""
+	config.TopologyBootstrap = false
+	config.MeasurementStrategy = "fixed"
+	config.MeasurementDuration = 30     // 30 seconds
+	config.MeasurementDurationMax = 120 // 2 minutes
+	config.MeasurementVariation = 0.25
+	config.PollInterval = 5 // 5 seconds
+	config.PollConcurrency = 32
+	config.PollHostConcurrency = 4
+	config.PollJitter = 1000 // 1 second
+	config.GraphTTL = 0      // never expire
+	config.StateFile = ""
+	config.StateInterval = 300            // 5 minutes
+	config.FetchPathRequestInterval = 300 // 5 minutes
+	config.FetchBrInfoInterval = 300      // 5 minutes
+	config.FetchBackoff = 5               // 5 seconds
+	config.FetchBackoffMax = 300          // 5 minutes
+	config.IngestAddress = ""
 	return config
 }
 
 func (config *SpeedCamConfig) String() string {
 	return fmt.Sprintf("{Episodes: %v, wDegree: %v, wCapacity: %v, wSuccess: %v, wActivity: %v, "+
-		"SpeedCamDiff: %v, Verbose: %v, ResultDir: %v, ScaleType: %v, ScaleParam: %3.3f, "+
-		"IntervalStrategy: %v, Interval: [%v - %v]}",
+		"wBetweenness: %v, wPathCentrality: %v, SpeedCamDiff: %v, Verbose: %v, ResultDir: %v, ScaleType: %v, "+
+		"ScaleParam: %3.3f, SelectionStrategy: %v, SelectionCoverage: %v, ScoreNormalization: %v, "+
+		"Seed: %v, "+
+		"IntervalStrategy: %v, Interval: [%v - %v], DetectionStrategy: %v, DetectionUtilization: %3.3f, "+
+		"DetectionOverflow: %v, DetectionSpikeFactor: %3.3f, CapacityFile: %v, TopologyDir: %v, TopologyBootstrap: %v, "+
+		"MeasurementStrategy: %v, Measurement: [%v - %v], MeasurementVariation: %3.3f, PollInterval: %v, "+
+		"PollConcurrency: %v, PollHostConcurrency: %v, PollJitter: %v, GraphTTL: %v, StateFile: %v, StateInterval: %v, "+
+		"FetchPathRequestInterval: %v, FetchBrInfoInterval: %v, FetchBackoff: %v, FetchBackoffMax: %v, "+
+		"IngestAddress: %v}",
 		config.Episodes, config.WeightDegree, config.WeightCapacity, config.WeightSuccess, config.WeightActivity,
-		config.SpeedCamDiff, config.Verbose, config.ResultDir, config.ScaleType, config.ScaleParam,
-		config.IntervalStrategy, config.IntervalWaitMin, config.IntervalWaitMax)
+		config.WeightBetweenness, config.WeightPathCentrality, config.SpeedCamDiff, config.Verbose, config.ResultDir,
+		config.ScaleType, config.ScaleParam, config.SelectionStrategy, config.SelectionCoverage,
+		config.ScoreNormalization, config.Seed,
+		config.IntervalStrategy, config.IntervalWaitMin, config.IntervalWaitMax, config.DetectionStrategy,
+		config.DetectionUtilization, config.DetectionOverflow, config.DetectionSpikeFactor, config.CapacityFile,
+		config.TopologyDir, config.TopologyBootstrap, config.MeasurementStrategy, config.MeasurementDuration, config.MeasurementDurationMax,
+		config.MeasurementVariation, config.PollInterval, config.PollConcurrency, config.PollHostConcurrency,
+		config.PollJitter, config.GraphTTL, config.StateFile, config.StateInterval,
+		config.FetchPathRequestInterval, config.FetchBrInfoInterval, config.FetchBackoff, config.FetchBackoffMax,
+		config.IngestAddress)
+}
+
+// Checks the config for contradicting values. The periodic polls, fetches and checkpoints need an interval of at least
+// one second, otherwise they would run in a busy loop.
+func (config *SpeedCamConfig) Validate() error {
+	intervals := []struct {
+		name  string
+		value uint
+	}{
+		{"poll interval", config.PollInterval},
+		{"state interval", config.StateInterval},
+		{"path request fetch interval", config.FetchPathRequestInterval},
+		{"BR information fetch interval", config.FetchBrInfoInterval},
+	}
+	for _, v := range intervals {
+		if v.value == 0 {
+			return errors.New(fmt.Sprintf("The %v must be at least one second", v.name))
+		}
+	}
+	if config.FetchBackoffMax < config.FetchBackoff {
+		return errors.New(fmt.Sprintf("Maximum fetch backoff %v is smaller than the fetch backoff %v",
+			config.FetchBackoffMax, config.FetchBackoff))
+	}
+	return nil
 }
 
 func (config *SpeedCamConfig) Scale(n int) int {
@@ -30,6 +30,10 @@ type speedCamInfo struct {
 	successes  *ring.Ring
 	activities *ring.Ring
 	capacity   datasize.ByteSize
+	// Normalized betweenness centrality (0.0 - 1.0). Only set in snapshots
+	betweenness float64
+	// Share of the path requests (0.0 - 1.0) whose path contains the AS. Only set in snapshots
+	pathCentrality float64
 }
 
 // Constructs a new information with assigned ISD-AS and initialized ring buffers.
@@ -49,6 +53,25 @@ func NewInfo(isdAs addr.IA, config *SpeedCamConfig) *speedCamInfo {
 	return info
 }
 
+// Copies the information including its history.
+func (scInfo *speedCamInfo) copy() *speedCamInfo {
+	info := *scInfo
+	info.successes = copyRing(scInfo.successes)
+	info.activities = copyRing(scInfo.activities)
+	return &info
+}
+
+// Copies the values of the ring in the same order, the current element stays the current.
+func copyRing(r *ring.Ring) *ring.Ring {
+	result := ring.New(r.Len())
+	for i := 0; i < r.Len(); i++ {
+		result.Value = r.Value
+		result = result.Next()
+		r = r.Next()
+	}
+	return result
+}
+
 // Add the result of SpeedCam to the history of this AS.
 func (scInfo *speedCamInfo) AddDetectionResult(isSuccess bool) {
 	scInfo.successes = scInfo.successes.Prev()
@@ -90,6 +113,24 @@ func (scInfo *speedCamInfo) AddActivity(start time.Time, duration time.Duration,
 	scInfo.activities.Value = *activity
 }
 
+// The average bandwidth of the previous episodes. Returns false if there is no history.
+func (scInfo *speedCamInfo) AverageBandwidth() (datasize.ByteSize, bool) {
+	var sum datasize.ByteSize
+	n := 0
+	scInfo.activities.Do(func(x interface{}) {
+		if x == nil {
+			return
+		}
+		sum += x.(activity).bandwidth
+		n++
+	})
+
+	if n == 0 {
+		return 0, false
+	}
+	return sum / datasize.ByteSize(n), true
+}
+
 func (scInfo *speedCamInfo) GetActivity() float64 {
 	var sum datasize.ByteSize
 	var totalCapacity datasize.ByteSize
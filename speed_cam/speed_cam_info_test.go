@@ -25,9 +25,9 @@ import (
 func TestNewInfo(t *testing.T) {
 	as17, _ := addr.IAFromString("1-7")
 	config := Default()
-	info := NewInfo(*as17, config)
+	info := NewInfo(as17, config)
 
-	if info.isdAs != *as17 {
+	if info.isdAs != as17 {
 		t.Errorf("Info contains wrong ISD-AS %v, but should be %v ", info.isdAs, as17)
 	}
 	if info.capacity != 0 {
@@ -44,7 +44,7 @@ func TestNewInfo(t *testing.T) {
 func TestSuccessRate(t *testing.T) {
 	as17, _ := addr.IAFromString("1-7")
 	config := Default()
-	info := NewInfo(*as17, config)
+	info := NewInfo(as17, config)
 
 	// oldest episode first to add
 	info.AddDetectionResult(true)
@@ -66,7 +66,7 @@ func TestSuccessRate(t *testing.T) {
 func TestActivityRate(t *testing.T) {
 	as17, _ := addr.IAFromString("1-7")
 	config := Default()
-	info := NewInfo(*as17, config)
+	info := NewInfo(as17, config)
 	// 10 GBytes/s
 	info.capacity = 10 * datasize.GB
 
@@ -18,111 +18,156 @@ package speed_cam
 import (
 	"github.com/scionproto/scion/go/lib/addr"
 	"math"
-	"math/rand"
-	"sort"
 )
 
 type SpeedCamSelector struct {
-	config *SpeedCamConfig
+	config   *SpeedCamConfig
+	strategy SelectionStrategy
 }
 
+// Creates a selector with the selection strategy of the config. Strategies like round-robin remember the previous
+// episodes, so the same selector must be used for all episodes.
 func Create(config *SpeedCamConfig) *SpeedCamSelector {
 	selector := new(SpeedCamSelector)
 	selector.config = config
+	selector.strategy = CreateSelectionStrategy(config)
 	return selector
 }
 
 type speedCamCandidate struct {
 	score float64
 	node  networkNode
+	// The features of the node and the features normalized across all candidates, which the score is calculated of
+	raw      ScoreFeatures
+	features ScoreFeatures
 }
 
-func (selector *SpeedCamSelector) SelectUsableSpeedCams(nodes map[addr.IA]networkNode) []networkNode {
+// Selects the SpeedCams among the nodes. The links are those of the graph, which the SpeedCams measure.
+func (selector *SpeedCamSelector) SelectUsableSpeedCams(nodes map[addr.IA]networkNode,
+	links map[LinkKey]*networkLink) []networkNode {
+	selected, _ := selector.SelectAndExplain(nodes, links)
+	return selected
+}
+
+// Selects the SpeedCams like SelectUsableSpeedCams and explains the score and the selection of every candidate.
+func (selector *SpeedCamSelector) SelectAndExplain(nodes map[addr.IA]networkNode,
+	links map[LinkKey]*networkLink) ([]networkNode, map[addr.IA]InspectionResultCandidate) {
+
+	candidates := selector.scoreCandidates(nodes)
+	selected, decisions := selector.selectCams(candidates, links)
+
+	explanations := make(map[addr.IA]InspectionResultCandidate, len(candidates))
+	for _, v := range decisions {
+		candidate := candidates[v.IsdAs]
+		explanations[v.IsdAs] = InspectionResultCandidate{
+			Score:      candidate.score,
+			Raw:        candidate.raw,
+			Normalized: candidate.features,
+			Weighted:   candidate.features.weighted(selector.config),
+			Selected:   v.Selected,
+			Reason:     v.Reason,
+			Draw:       v.Draw,
+			Drawn:      v.Drawn,
+		}
+	}
+	return selected, explanations
+}
+
+// Scores the nodes relative to each other. The features are normalized across the nodes before the weights are
+// applied, then the scores are scaled to 0.0 - 1.0.
+func (selector *SpeedCamSelector) scoreCandidates(nodes map[addr.IA]networkNode) map[addr.IA]*speedCamCandidate {
 	candidates := make(map[addr.IA]*speedCamCandidate)
+	candidateList := make([]*speedCamCandidate, 0, len(nodes))
 	for k, v := range nodes {
 		candidates[k] = selector.calculateScore(v)
+		candidateList = append(candidateList, candidates[k])
 	}
 
+	normalizeFeatures(candidateList, selector.config)
+	for _, v := range candidates {
+		v.score = v.features.weightedSum(selector.config)
+	}
 	selector.normalizeScores(candidates)
-
-	return selector.selectCams(candidates)
+	return candidates
 }
 
+// Calculates the score of the node by its raw features. They are of different magnitudes, so the scores of
+// scoreCandidates normalize them first.
 func (selector *SpeedCamSelector) calculateScore(node networkNode) *speedCamCandidate {
-	var score = 0.0
 	info := node.info
-	score += float64(info.degree) * selector.config.WeightDegree
-	score += float64(info.capacity) * selector.config.WeightCapacity
-	score += float64(info.GetActivity()) * selector.config.WeightActivity
-	score += float64(info.SuccessRate()) * selector.config.WeightSuccess
-
 	candidate := new(speedCamCandidate)
-	candidate.score = score
 	candidate.node = node
+	candidate.raw = ScoreFeatures{
+		Degree:         float64(info.degree),
+		Capacity:       float64(info.capacity),
+		Activity:       info.GetActivity(),
+		Success:        info.SuccessRate(),
+		Betweenness:    info.betweenness,
+		PathCentrality: info.pathCentrality,
+	}
+	candidate.features = candidate.raw
+	candidate.score = candidate.raw.weightedSum(selector.config)
 	return candidate
 }
 
+// Scales the scores to 0.0 - 1.0, so they can be used as chance. Negative scores, e.g. of z-scores, are shifted
+// first.
 func (selector *SpeedCamSelector) normalizeScores(candidates map[addr.IA]*speedCamCandidate) {
-	maxScore := -1.0
+	minScore := 0.0
+	for _, v := range candidates {
+		minScore = math.Min(minScore, v.score)
+	}
 
+	maxScore := 0.0
 	for _, v := range candidates {
+		v.score -= minScore
 		maxScore = math.Max(maxScore, v.score)
 	}
+	if maxScore == 0 {
+		return
+	}
 
 	for _, v := range candidates {
 		v.score = v.score / maxScore
 	}
 }
 
-func (selector *SpeedCamSelector) selectCams(candidates map[addr.IA]*speedCamCandidate) []networkNode {
+// Selects the SpeedCams with the strategy of the selector. Returns the decisions of the strategy about all
+// candidates as well.
+func (selector *SpeedCamSelector) selectCams(candidates map[addr.IA]*speedCamCandidate,
+	links map[LinkKey]*networkLink) ([]networkNode, []SelectionDecision) {
 
 	count := selector.config.Scale(len(candidates)) + selector.config.SpeedCamDiff
-	MyLogger.Debugf("Candidates: %v, SpeedCam count: %v", len(candidates), count)
-	var i = 0
-
-	selectedCams := make(map[addr.IA]*speedCamCandidate)
+	MyLogger.Debugf("Candidates: %v, SpeedCam count: %v, strategy: %v", len(candidates), count,
+		selector.config.SelectionStrategy)
 
-	for k, v := range candidates {
-		MyLogger.Debugf("Candidate: %v, chance: %.4f", k, v.score)
-	}
-	for k, v := range candidates {
-		// Is the speedCam selected?
-		chance := rand.Float64()
-		if chance <= v.score {
-			// Add speedCam to selection
-			selectedCams[k] = v
-			i += 1
-			// Are there enough speedCams?
-			if i == count {
-				break
-			}
-		}
+	// The map order differs between runs
+	isdAses := make([]addr.IA, 0, len(candidates))
+	for k := range candidates {
+		isdAses = append(isdAses, k)
 	}
+	sortIsdAses(isdAses)
 
-	// Are not enough speedCams selected -> select highest chance
-	if i != count {
-		var notSelectedCams []speedCamCandidate
-		for k, v := range candidates {
-			_, ok := selectedCams[k]
-			if !ok {
-				notSelectedCams = append(notSelectedCams, *v)
-			}
-		}
-
-		// Sort in descending order
-		sort.Slice(notSelectedCams, func(i, j int) bool {
-			return notSelectedCams[i].score >= notSelectedCams[j].score
-		})
+	linksPerIsdAs := make(map[addr.IA][]LinkKey)
+	for k := range links {
+		linksPerIsdAs[k.A] = append(linksPerIsdAs[k.A], k)
+		linksPerIsdAs[k.B] = append(linksPerIsdAs[k.B], k)
+	}
 
-		for j := 0; i < count && j < len(notSelectedCams); i, j = i+1, j+1 {
-			element := notSelectedCams[j]
-			selectedCams[element.node.IsdAs] = &element
-		}
+	selectionCandidates := make([]SelectionCandidate, 0, len(candidates))
+	for _, k := range isdAses {
+		v := candidates[k]
+		MyLogger.Debugf("Candidate: %v, chance: %.4f", k, v.score)
+		candidateLinks := linksPerIsdAs[k]
+		sortLinkKeys(candidateLinks)
+		selectionCandidates = append(selectionCandidates,
+			SelectionCandidate{IsdAs: k, Score: v.score, Links: candidateLinks})
 	}
 
+	decisions := selector.strategy.Select(selectionCandidates, count)
 	var result []networkNode
-	for _, v := range selectedCams {
-		result = append(result, v.node)
+	for _, v := range SelectedIsdAses(decisions) {
+		result = append(result, candidates[v].node)
 	}
-	return result
+	return result, decisions
 }
@@ -30,29 +30,33 @@ func TestNewSelector(t *testing.T) {
 }
 
 func TestSelection(t *testing.T) {
-	selector := Create(Default())
+	config := Default()
+	config.ScaleType = "const"
+	config.ScaleParam = 1
+	selector := Create(config)
 
-	connections := make(map[addr.ISD_AS][]addr.ISD_AS)
+	connections := make(map[addr.IA][]addr.IA)
 
 	as17, _ := addr.IAFromString("1-7")
 	as18, _ := addr.IAFromString("1-8")
 	as19, _ := addr.IAFromString("1-9")
 
-	connections[*as17] = make([]addr.ISD_AS, 2)
-	connections[*as18] = make([]addr.ISD_AS, 2)
-	connections[*as19] = make([]addr.ISD_AS, 2)
+	connections[as17] = make([]addr.IA, 2)
+	connections[as18] = make([]addr.IA, 2)
+	connections[as19] = make([]addr.IA, 2)
 
-	connections[*as17] = append(connections[*as17], *as18, *as19)
-	connections[*as18] = append(connections[*as18], *as17, *as19)
-	connections[*as19] = append(connections[*as19], *as17, *as18)
+	connections[as17] = append(connections[as17], as18, as19)
+	connections[as18] = append(connections[as18], as17, as19)
+	connections[as19] = append(connections[as19], as17, as18)
 
-	graph := Load(connections, Default())
+	graph := Load(connections, config)
 	// Increase the dataSize capacity for AS17 so for this test the selected candidate is AS 1-7 (chance is much higher
 	// than for other ASes)
-	info := graph.nodes[*as17].info
+	info := graph.nodes[as17].info
 	info.capacity = 10 * datasize.GB
 
-	selectedCams := selector.SelectSpeedCams(graph)
+	snapshot := graph.Snapshot()
+	selectedCams := selector.SelectUsableSpeedCams(snapshot.nodes, snapshot.links)
 	expected := 1
 	if len(selectedCams) != expected {
 		t.Errorf("Selected cames should be %v, but it was %v", expected, len(selectedCams))
@@ -16,12 +16,16 @@
 package speed_cam
 
 import (
+	"context"
+	"fmt"
 	"github.com/scionproto/scion/go/lib/addr"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -47,31 +51,247 @@ func loadPrometheusResult() []byte {
 
 func TestFetchResult(t *testing.T) {
 
+	counter = 1
 	ts := httptest.NewServer(http.HandlerFunc(servePrometheusResults))
 	defer ts.Close()
 
 	sourceIsdAs, _ := addr.IAFromString("1-10")
 	targetIsdAs, _ := addr.IAFromString("1-11")
-	cam := CreateSpeedCam(*sourceIsdAs, 9*time.Second)
+	cam := CreateSpeedCam(sourceIsdAs, 6*time.Second)
 
 	index := strings.LastIndex(ts.URL, ":")
-	ip := ts.URL[:index]
+	ip := strings.TrimPrefix(ts.URL[:index], "http://")
 	port, _ := strconv.ParseInt(ts.URL[index+1:], 10, 32)
 	measurementPoints := []PrometheusClientInfo{
-		{Ip: ip, Port: int(port), BrId: "1-10-1", SourceIsdAs: *sourceIsdAs, TargetIsdAs: *targetIsdAs},
+		{Ip: ip, Port: int(port), BrId: "1-10-1", SourceIsdAs: sourceIsdAs, TargetIsdAs: targetIsdAs, IfId: 16},
 	}
-	resultMap := cam.Measure(measurementPoints, 3*time.Second)
+	resultMap := cam.Measure(context.Background(), measurementPoints, 3*time.Second)
 
+	// Only the samples of sock="intf:16" are considered. The polls are about 3 seconds apart
 	expectedSpeedCamResults := []SpeedCamResult{
-		{BandwidthIn: 20619, BandwidthOut: 14278},
-		{BandwidthIn: 4474, BandwidthOut: 3894},
-		{BandwidthIn: 0, BandwidthOut: 0}}
+		{BandwidthIn: 14077.0 / 3, BandwidthOut: 17729.0 / 3, PacketsIn: 89.0 / 3, PacketsOut: 90.0 / 3},
+		{BandwidthIn: 3813.0 / 3, BandwidthOut: 3729.0 / 3, PacketsIn: 22.0 / 3, PacketsOut: 21.0 / 3}}
 
-	results := resultMap[*targetIsdAs]
-	for i := 0; i < 3; i++ {
+	results := resultMap[NewLinkKey(sourceIsdAs, 16, targetIsdAs, 0)]
+	if len(results) != len(expectedSpeedCamResults) {
+		t.Fatalf("Expected %v results, but was %v\n", len(expectedSpeedCamResults), len(results))
+	}
+	for i := 0; i < len(expectedSpeedCamResults); i++ {
 		result := expectedSpeedCamResults[i]
-		if result.BandwidthIn != results[i].BandwidthIn || result.BandwidthOut != results[i].BandwidthOut {
+		if !approximately(result.BandwidthIn, results[i].BandwidthIn) ||
+			!approximately(result.BandwidthOut, results[i].BandwidthOut) ||
+			!approximately(result.PacketsIn, results[i].PacketsIn) ||
+			!approximately(result.PacketsOut, results[i].PacketsOut) {
 			t.Errorf("Expected %v, but was %v\n", result, results[i])
 		}
 	}
+
+	statistics := results.Statistics()
+	if !approximately(statistics.BandwidthIn.Max, 14077.0/3) || !approximately(statistics.BandwidthIn.Min, 3813.0/3) {
+		t.Errorf("Expected the window statistics of the results, but was %v\n", statistics.BandwidthIn)
+	}
+}
+
+func TestMeasureCancelled(t *testing.T) {
+
+	counter = 1
+	ts := httptest.NewServer(http.HandlerFunc(servePrometheusResults))
+	defer ts.Close()
+
+	sourceIsdAs, _ := addr.IAFromString("1-10")
+	targetIsdAs, _ := addr.IAFromString("1-11")
+	cam := CreateSpeedCam(sourceIsdAs, time.Minute)
+
+	index := strings.LastIndex(ts.URL, ":")
+	ip := strings.TrimPrefix(ts.URL[:index], "http://")
+	port, _ := strconv.ParseInt(ts.URL[index+1:], 10, 32)
+	measurementPoints := []PrometheusClientInfo{
+		{Ip: ip, Port: int(port), BrId: "1-10-1", SourceIsdAs: sourceIsdAs, TargetIsdAs: targetIsdAs, IfId: 16},
+	}
+
+	// Cancelled after the second of many polls
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resultMap := cam.Measure(ctx, measurementPoints, time.Second)
+
+	if duration := time.Since(start); duration > 5*time.Second {
+		t.Errorf("Expected the measurement to stop after cancelling, but it took %v", duration)
+	}
+	results := resultMap[NewLinkKey(sourceIsdAs, 16, targetIsdAs, 0)]
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 partial result, but was %v\n", results)
+	}
+	if expected := 14077.0 / results[0].Duration.Seconds(); !approximately(expected, results[0].BandwidthIn) {
+		t.Errorf("Expected %v B/s in, but was %v", expected, results[0].BandwidthIn)
+	}
+}
+
+// Two parallel links to the same neighbor must not overwrite each other's results
+func TestMeasureParallelLinks(t *testing.T) {
+
+	var polls int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&polls, 1)
+		fmt.Fprintf(w, "border_input_bytes_total{sock=\"intf:16\"} %v\n", n*1000)
+		fmt.Fprintf(w, "border_output_bytes_total{sock=\"intf:16\"} 0\n")
+		fmt.Fprintf(w, "border_input_bytes_total{sock=\"intf:18\"} %v\n", n*5000)
+		fmt.Fprintf(w, "border_output_bytes_total{sock=\"intf:18\"} 0\n")
+	}))
+	defer ts.Close()
+
+	sourceIsdAs, _ := addr.IAFromString("1-10")
+	targetIsdAs, _ := addr.IAFromString("1-11")
+	cam := CreateSpeedCam(sourceIsdAs, time.Second)
+
+	index := strings.LastIndex(ts.URL, ":")
+	ip := strings.TrimPrefix(ts.URL[:index], "http://")
+	port, _ := strconv.ParseInt(ts.URL[index+1:], 10, 32)
+	measurementPoints := []PrometheusClientInfo{
+		{Ip: ip, Port: int(port), BrId: "1-10-1", SourceIsdAs: sourceIsdAs, TargetIsdAs: targetIsdAs, IfId: 16},
+		{Ip: ip, Port: int(port), BrId: "1-10-2", SourceIsdAs: sourceIsdAs, TargetIsdAs: targetIsdAs, IfId: 18},
+	}
+	resultMap := cam.Measure(context.Background(), measurementPoints, time.Second)
+
+	if len(resultMap) != 2 {
+		t.Fatalf("Expected results of 2 links, but was %v", resultMap)
+	}
+	for _, ifId := range []int{16, 18} {
+		results := resultMap[NewLinkKey(sourceIsdAs, ifId, targetIsdAs, 0)]
+		if len(results) == 0 || results[0].IfId != ifId || results[0].BrId != measurementPoints[(ifId-16)/2].BrId {
+			t.Errorf("Expected results of interface %v, but was %v", ifId, results)
+		}
+	}
+}
+
+// Equality with 1% tolerance for rates depending on the real time between polls
+func approximately(expected float64, actual float64) bool {
+	return math.Abs(expected-actual) <= math.Abs(expected)*0.01
+}
+
+func TestInterfaceSocket(t *testing.T) {
+
+	counter = 1
+	metrics, err := ParsePrometheusMetrics(loadPrometheusResult())
+	if err != nil {
+		t.Fatalf("error parsing metrics: %v", err)
+	}
+
+	sock, err := interfaceSocket(metrics, PrometheusClientInfo{IfId: 3})
+	if err != nil || sock != "intf:3" {
+		t.Errorf("Expected sock intf:3, but was '%v' (err: %v)", sock, err)
+	}
+
+	// Unknown interface id -> the only interface of the BR
+	sock, err = interfaceSocket(metrics, PrometheusClientInfo{})
+	if err != nil || sock != "intf:16" {
+		t.Errorf("Expected sock intf:16, but was '%v' (err: %v)", sock, err)
+	}
+}
+
+func TestDifferentiateResultReset(t *testing.T) {
+	start := time.Date(2018, 02, 23, 10, 0, 0, 0, time.Local)
+	processStart := start.Add(-time.Hour)
+
+	resultStart := SpeedCamResult{Timestamp: start, BandwidthIn: 1000, BandwidthOut: 2000, PacketsIn: 10,
+		ProcessStart: processStart}
+
+	// Counters increased, no reset
+	resultEnd := SpeedCamResult{Timestamp: start.Add(2 * time.Second), BandwidthIn: 3000, BandwidthOut: 6000,
+		PacketsIn: 20, ProcessStart: processStart}
+	result := differentiateResult(resultStart, resultEnd)
+	if result.Reset || result.BandwidthIn != 1000 || result.BandwidthOut != 2000 || result.PacketsIn != 5 {
+		t.Errorf("Unexpected result without reset: %v", result)
+	}
+
+	// Two counters went backwards and restarted from zero, the output counter kept increasing
+	resultEnd = SpeedCamResult{Timestamp: start.Add(2 * time.Second), BandwidthIn: 400, BandwidthOut: 6000,
+		PacketsIn: 4, ProcessStart: processStart}
+	result = differentiateResult(resultStart, resultEnd)
+	if !result.Reset || result.BandwidthIn != 200 || result.BandwidthOut != 2000 || result.PacketsIn != 2 {
+		t.Errorf("Unexpected result after counter reset: %v", result)
+	}
+
+	// The BR restarted, but its counters are already higher than before
+	resultEnd = SpeedCamResult{Timestamp: start.Add(2 * time.Second), BandwidthIn: 3000, BandwidthOut: 6000,
+		PacketsIn: 20, ProcessStart: start.Add(time.Second)}
+	result = differentiateResult(resultStart, resultEnd)
+	if !result.Reset || result.BandwidthIn != 1500 || result.BandwidthOut != 3000 || result.PacketsIn != 10 {
+		t.Errorf("Unexpected result after restart: %v", result)
+	}
+}
+
+// Polls less than a second apart must not lose precision or divide by zero
+func TestDifferentiateSubSecond(t *testing.T) {
+	start := time.Date(2018, 02, 23, 10, 0, 0, 0, time.Local)
+	results := []SpeedCamResult{
+		{Timestamp: start, BandwidthIn: 1000},
+		{Timestamp: start.Add(250 * time.Millisecond), BandwidthIn: 1100},
+		{Timestamp: start.Add(250 * time.Millisecond), BandwidthIn: 1100},
+		{Timestamp: start.Add(500 * time.Millisecond), BandwidthIn: 1150},
+	}
+
+	diffResults := differentiateResults(results)
+	if diffResults.err != nil {
+		t.Fatalf("error: %v", diffResults.err)
+	}
+	// The sample without duration is skipped
+	if len(diffResults.results) != 2 {
+		t.Fatalf("Expected 2 results, but was %v", diffResults.results)
+	}
+	if diffResults.results[0].BandwidthIn != 400 || diffResults.results[1].BandwidthIn != 200 {
+		t.Errorf("Expected rates 400 and 200, but was %v", diffResults.results)
+	}
+	expectedTime := start.Add(125 * time.Millisecond)
+	if !diffResults.results[0].Timestamp.Equal(expectedTime) {
+		t.Errorf("Expected timestamp %v, but was %v", expectedTime, diffResults.results[0].Timestamp)
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	var results SpeedCamResults
+	for i := 1; i <= 20; i++ {
+		results = append(results, SpeedCamResult{BandwidthIn: float64(i * 100), Reset: i == 3, Duration: time.Second})
+	}
+
+	statistics := results.Statistics()
+	expected := RateStatistics{Min: 100, Max: 2000, Mean: 1050, P95: 1900}
+	if statistics.BandwidthIn != expected {
+		t.Errorf("Expected %v, but was %v", expected, statistics.BandwidthIn)
+	}
+	if statistics.Samples != 20 || statistics.Resets != 1 || statistics.Window != 20*time.Second {
+		t.Errorf("Expected 20 samples, 1 reset and 20s, but was %v", statistics)
+	}
+}
+
+func TestAdaptiveIsVarying(t *testing.T) {
+	isdAs, _ := addr.IAFromString("1-10")
+	start := time.Date(2018, 02, 23, 10, 0, 0, 0, time.Local)
+	steady := []SpeedCamResult{
+		{Timestamp: start, BandwidthIn: 0},
+		{Timestamp: start.Add(time.Second), BandwidthIn: 1000},
+		{Timestamp: start.Add(2 * time.Second), BandwidthIn: 2010},
+	}
+	bursty := []SpeedCamResult{
+		{Timestamp: start, BandwidthIn: 0},
+		{Timestamp: start.Add(time.Second), BandwidthIn: 100},
+		{Timestamp: start.Add(2 * time.Second), BandwidthIn: 5000},
+	}
+
+	cam := CreateAdaptiveSpeedCam(isdAs, 10*time.Second, 60*time.Second, 0.25)
+	if cam.isVarying(steady) {
+		t.Error("Expected steady rates not to vary")
+	}
+	if !cam.isVarying(bursty) {
+		t.Error("Expected bursty rates to vary")
+	}
+	if cam.isVarying(bursty[:2]) {
+		t.Error("Expected a single rate not to vary")
+	}
+
+	// A fixed measurement never continues
+	cam = CreateSpeedCam(isdAs, 10*time.Second)
+	if cam.isVarying(bursty) {
+		t.Error("Expected fixed measurement not to vary")
+	}
 }
@@ -0,0 +1,277 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/c2h5oh/datasize"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The relation of the neighbor to an AS, as given by LinkTo in the SCION topology files.
+type LinkType string
+
+const (
+	LinkCore   LinkType = "CORE"
+	LinkParent LinkType = "PARENT"
+	LinkChild  LinkType = "CHILD"
+	LinkPeer   LinkType = "PEER"
+)
+
+// The type of the same link seen from the neighbor.
+func (linkType LinkType) reverse() LinkType {
+	switch linkType {
+	case LinkParent:
+		return LinkChild
+	case LinkChild:
+		return LinkParent
+	default:
+		return linkType
+	}
+}
+
+// Parses the LinkTo of a topology file. Empty if it is missing.
+func parseLinkType(s string) (LinkType, error) {
+	linkType := LinkType(strings.ToUpper(s))
+	switch linkType {
+	case "", LinkCore, LinkParent, LinkChild, LinkPeer:
+		return linkType, nil
+	default:
+		return "", errors.New(fmt.Sprintf("Unsupported link type '%v'", s))
+	}
+}
+
+// An interface of a border router in a SCION topology file.
+type TopologyInterface struct {
+	Source addr.IA
+	IfId   int
+	BrId   string
+	Target addr.IA
+	// The interface of the target, found in the topology file of the target. Zero if unknown
+	TargetIfId int
+	// The relation of the target to the source. Empty if unknown
+	LinkType LinkType
+	// Bytes per second, zero if unknown
+	Bandwidth datasize.ByteSize
+
+	// Underlay addresses to find the interface of the target
+	public topologyAddress
+	remote topologyAddress
+}
+
+type topologyFile struct {
+	ISD_AS        string
+	BorderRouters map[string]struct {
+		Interfaces map[string]struct {
+			ISD_AS    string
+			LinkTo    string
+			Bandwidth uint64
+			Public    topologyAddress
+			Remote    topologyAddress
+		}
+	}
+}
+
+type topologyAddress struct {
+	Addr   string
+	L4Port int
+}
+
+// Loads the interfaces of all topology.json files in the directory, for example the gen directory of SCION.
+func LoadTopologyDir(dir string) ([]TopologyInterface, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Name() == "topology.json" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return LoadTopologyFiles(files...)
+}
+
+// Loads the interfaces of the topology files. Interfaces found in multiple files, like the copies of an AS's
+// topology for each of its services, are loaded once. The interfaces of both ends of a link are paired if the
+// topologies of both ASes are given.
+func LoadTopologyFiles(files ...string) ([]TopologyInterface, error) {
+	type interfaceKey struct {
+		source addr.IA
+		ifId   int
+	}
+	loaded := make(map[interfaceKey]bool)
+
+	var interfaces []TopologyInterface
+	for _, file := range files {
+		fileInterfaces, err := loadTopologyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range fileInterfaces {
+			key := interfaceKey{source: v.Source, ifId: v.IfId}
+			if !loaded[key] {
+				loaded[key] = true
+				interfaces = append(interfaces, v)
+			}
+		}
+	}
+
+	pairInterfaces(interfaces)
+	sort.Slice(interfaces, func(i, j int) bool {
+		if interfaces[i].Source != interfaces[j].Source {
+			return isdAsLess(interfaces[i].Source, interfaces[j].Source)
+		}
+		return interfaces[i].IfId < interfaces[j].IfId
+	})
+	return interfaces, nil
+}
+
+func loadTopologyFile(file string) ([]TopologyInterface, error) {
+	readBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var topology topologyFile
+	err = json.Unmarshal(readBytes, &topology)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error parsing topology file '%v', err: %v", file, err))
+	}
+
+	source, err := addr.IAFromString(topology.ISD_AS)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid ISD-AS in topology file '%v', err: %v", file, err))
+	}
+
+	var interfaces []TopologyInterface
+	for brId, br := range topology.BorderRouters {
+		for k, v := range br.Interfaces {
+			ifId, err := strconv.Atoi(k)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid interface id '%v' in topology file '%v'", k, file))
+			}
+			target, err := addr.IAFromString(v.ISD_AS)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid ISD-AS of interface %v in topology file '%v'", k, file))
+			}
+			linkType, err := parseLinkType(v.LinkTo)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid link type of interface %v in topology file '%v', "+
+					"err: %v", k, file, err))
+			}
+			interfaces = append(interfaces, TopologyInterface{Source: source, IfId: ifId, BrId: brId, Target: target,
+				LinkType: linkType, Bandwidth: datasize.ByteSize(v.Bandwidth) * topologyBandwidthUnit,
+				public: v.Public, remote: v.Remote})
+		}
+	}
+	return interfaces, nil
+}
+
+// Sets the target interfaces. The remote address of an interface is the public address of the target's
+// interface. Without addresses, a single link between two ASes is paired anyway.
+func pairInterfaces(interfaces []TopologyInterface) {
+	for i := range interfaces {
+		var candidates []int
+		for j, other := range interfaces {
+			if other.Source == interfaces[i].Target && other.Target == interfaces[i].Source {
+				candidates = append(candidates, j)
+			}
+		}
+
+		for _, j := range candidates {
+			if len(interfaces[i].remote.Addr) != 0 && interfaces[i].remote == interfaces[j].public {
+				interfaces[i].TargetIfId = interfaces[j].IfId
+			}
+		}
+		if interfaces[i].TargetIfId == 0 && len(candidates) == 1 && interfaces[i].isOnlyLinkTo(interfaces) {
+			interfaces[i].TargetIfId = interfaces[candidates[0]].IfId
+		}
+	}
+}
+
+func (topologyInterface TopologyInterface) isOnlyLinkTo(interfaces []TopologyInterface) bool {
+	for _, v := range interfaces {
+		if v.Source == topologyInterface.Source && v.Target == topologyInterface.Target &&
+			v.IfId != topologyInterface.IfId {
+			return false
+		}
+	}
+	return true
+}
+
+// The link capacities of the interfaces with a known bandwidth.
+func TopologyCapacities(interfaces []TopologyInterface) []LinkCapacity {
+	var capacities []LinkCapacity
+	for _, v := range interfaces {
+		if v.Bandwidth == 0 {
+			continue
+		}
+		capacities = append(capacities, LinkCapacity{Source: v.Source, Target: v.Target, IfId: v.IfId,
+			Capacity: v.Bandwidth})
+	}
+	return capacities
+}
+
+// Creates a graph with the ASes and links of the topology interfaces.
+func LoadTopology(interfaces []TopologyInterface, config *SpeedCamConfig) *NetworkGraph {
+	graph := CreateEmpty(config)
+	graph.AddTopology(interfaces)
+	return graph
+}
+
+// Adds the ASes and links of the topology interfaces to the graph. Already existing ones are seen again and learn
+// the interfaces, border routers and link types. They never expire, even if they never show up in path requests.
+func (graph *NetworkGraph) AddTopology(interfaces []TopologyInterface) {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	now := time.Now()
+	for _, v := range interfaces {
+		// Fails only for already existing ASes
+		graph.addIsdAs(v.Source)
+		graph.addIsdAs(v.Target)
+		graph.markSeen(v.Source, now)
+		graph.markSeen(v.Target, now)
+		graph.static[v.Source] = true
+		graph.static[v.Target] = true
+		if !graph.connected(v.Source, v.Target) {
+			graph.connect(v.Source, v.Target)
+		}
+
+		link := graph.link(NewLinkKey(v.Source, v.IfId, v.Target, v.TargetIfId))
+		link.setBrId(v.Source, v.BrId)
+		link.lastSeen = now
+		link.static = true
+		if v.Source == link.key.A {
+			link.linkType = v.LinkType
+		} else {
+			link.linkType = v.LinkType.reverse()
+		}
+	}
+}
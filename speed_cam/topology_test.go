@@ -0,0 +1,172 @@
+// Copyright 2018 ETH Zurich, OvGU Magdeburg
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for a bandwidth regulation algorithm named SpeedCam. Further information here: URL_TO_THESIS
+package speed_cam
+
+import (
+	"github.com/scionproto/scion/go/lib/addr"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// Two parallel links between 1-10 and 1-11, crossed by their addresses, and a link to 1-12 without topology
+const testTopology110 = `{
+  "ISD_AS": "1-10",
+  "BorderRouters": {
+    "br1-10-1": {
+      "Interfaces": {
+        "16": {"ISD_AS": "1-11", "Bandwidth": 1000, "LinkTo": "PARENT",
+          "Public": {"Addr": "127.0.0.1", "L4Port": 50000}, "Remote": {"Addr": "127.0.0.2", "L4Port": 50002}},
+        "18": {"ISD_AS": "1-11", "Bandwidth": 1000, "LinkTo": "PARENT",
+          "Public": {"Addr": "127.0.0.1", "L4Port": 50001}, "Remote": {"Addr": "127.0.0.2", "L4Port": 50001}}
+      }
+    },
+    "br1-10-2": {
+      "Interfaces": {
+        "17": {"ISD_AS": "1-12", "Bandwidth": 8, "LinkTo": "child"}
+      }
+    }
+  }
+}`
+
+const testTopology111 = `{
+  "ISD_AS": "1-11",
+  "BorderRouters": {
+    "br1-11-1": {
+      "Interfaces": {
+        "1": {"ISD_AS": "1-10", "Bandwidth": 1000, "LinkTo": "CHILD",
+          "Public": {"Addr": "127.0.0.2", "L4Port": 50001}, "Remote": {"Addr": "127.0.0.1", "L4Port": 50001}},
+        "2": {"ISD_AS": "1-10", "Bandwidth": 1000, "LinkTo": "CHILD",
+          "Public": {"Addr": "127.0.0.2", "L4Port": 50002}, "Remote": {"Addr": "127.0.0.1", "L4Port": 50000}}
+      }
+    }
+  }
+}`
+
+func writeTestTopologies(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "speedcam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every service of an AS has a copy of its topology
+	for _, v := range []struct{ dir, topology string }{
+		{path.Join(dir, "ISD1", "AS10", "br1-10-1"), testTopology110},
+		{path.Join(dir, "ISD1", "AS10", "br1-10-2"), testTopology110},
+		{path.Join(dir, "ISD1", "AS11", "br1-11-1"), testTopology111},
+	} {
+		os.MkdirAll(v.dir, 0777)
+		ioutil.WriteFile(path.Join(v.dir, "topology.json"), []byte(v.topology), 0666)
+	}
+	return dir
+}
+
+func TestLoadTopologyDir(t *testing.T) {
+	dir := writeTestTopologies(t)
+	defer os.RemoveAll(dir)
+
+	interfaces, err := LoadTopologyDir(dir)
+	if err != nil {
+		t.Fatalf("error loading topology: %v", err)
+	}
+	if len(interfaces) != 5 {
+		t.Fatalf("Expected 5 interfaces, but was %v", interfaces)
+	}
+
+	// Sorted by AS and interface
+	expected := []struct {
+		ifId       int
+		targetIfId int
+		linkType   LinkType
+	}{{16, 2, LinkParent}, {17, 0, LinkChild}, {18, 1, LinkParent}, {1, 18, LinkChild}, {2, 16, LinkChild}}
+	for i, v := range expected {
+		actual := interfaces[i]
+		if actual.IfId != v.ifId || actual.TargetIfId != v.targetIfId || actual.LinkType != v.linkType {
+			t.Errorf("Expected interface %v to %v (%v), but was %v", v.ifId, v.targetIfId, v.linkType, actual)
+		}
+	}
+}
+
+func TestLoadTopologyGraph(t *testing.T) {
+	dir := writeTestTopologies(t)
+	defer os.RemoveAll(dir)
+
+	interfaces, err := LoadTopologyDir(dir)
+	if err != nil {
+		t.Fatalf("error loading topology: %v", err)
+	}
+	graph := LoadTopology(interfaces, Default())
+
+	as110, _ := addr.IAFromString("1-10")
+	as111, _ := addr.IAFromString("1-11")
+	as112, _ := addr.IAFromString("1-12")
+	snapshot := graph.Snapshot()
+	if snapshot.Size() != 3 {
+		t.Fatalf("Expected 3 ASes, but was %v", snapshot.Size())
+	}
+	if degree := snapshot.nodes[as110].info.degree; degree != 2 {
+		t.Errorf("Expected degree 2 of 1-10, but was %v", degree)
+	}
+
+	expected := []LinkKey{NewLinkKey(as110, 16, as111, 2), NewLinkKey(as110, 18, as111, 1),
+		NewLinkKey(as110, 17, as112, 0)}
+	links := snapshot.Links()
+	if len(links) != len(expected) {
+		t.Fatalf("Expected links %v, but was %v", expected, links)
+	}
+	for i, v := range expected {
+		if links[i] != v {
+			t.Errorf("Expected link %v, but was %v", v, links[i])
+		}
+	}
+
+	link := snapshot.links[expected[0]]
+	if link.linkType != LinkParent || link.brIdA != "br1-10-1" || link.brIdB != "br1-11-1" {
+		t.Errorf("Unexpected link metadata %v", link)
+	}
+}
+
+// ASes and links of the topology files stay in the graph, even if they are never seen again
+func TestLoadTopologyNotExpiring(t *testing.T) {
+	dir := writeTestTopologies(t)
+	defer os.RemoveAll(dir)
+
+	interfaces, err := LoadTopologyDir(dir)
+	if err != nil {
+		t.Fatalf("error loading topology: %v", err)
+	}
+	config := Default()
+	config.GraphTTL = 60
+	graph := LoadTopology(interfaces, config)
+
+	as110, _ := addr.IAFromString("1-10")
+	as113, _ := addr.IAFromString("1-13")
+	graph.AddIsdAs(as113)
+	graph.ConnectIsdAses(as110, as113)
+
+	events := graph.Expire(time.Now().Add(2 * time.Hour))
+	if len(events) != 2 || graph.Contains(as113) {
+		t.Errorf("Expected only the removal of 1-13 and its link, but was %v", events)
+	}
+	snapshot := graph.Snapshot()
+	if snapshot.Size() != 3 || len(snapshot.Links()) != 3 {
+		t.Errorf("Expected the 3 ASes and 3 links of the topology, but was %v", snapshot.Links())
+	}
+	if degree := snapshot.nodes[as110].info.degree; degree != 2 {
+		t.Errorf("Expected degree 2 of 1-10, but was %v", degree)
+	}
+}
@@ -16,6 +16,7 @@
 package speed_cam
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/op/go-logging"
 	"io/ioutil"
@@ -35,7 +36,8 @@ func init() {
 	logging.SetBackend(backendFormatter)
 }
 
-func FetchData(restResourceUrl string) ([]byte, error) {
+// Fetches the resource. The request is aborted when the context is cancelled.
+func FetchData(ctx context.Context, restResourceUrl string) ([]byte, error) {
 	var body []byte
 
 	client := http.Client{
@@ -47,6 +49,7 @@ func FetchData(restResourceUrl string) ([]byte, error) {
 		return body, err
 	}
 
+	req = req.WithContext(ctx)
 	req.Header.Set("User-Agent", "speedcam-inspector")
 
 	res, err := client.Do(req)
@@ -54,13 +57,14 @@ func FetchData(restResourceUrl string) ([]byte, error) {
 		return body, err
 	}
 
+	defer res.Body.Close()
 	body, err = ioutil.ReadAll(res.Body)
 	return body, err
 }
 
-func FetchJsonData(restResourceUrl string, data interface{}) error {
+func FetchJsonData(ctx context.Context, restResourceUrl string, data interface{}) error {
 
-	readBytes, err := FetchData(restResourceUrl)
+	readBytes, err := FetchData(ctx, restResourceUrl)
 
 	if err != nil {
 		return err
@@ -18,14 +18,10 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"encoding/json"
 	"flag"
 	sc "github.com/Meldanor/SCIONLab_SpeedCam/speed_cam"
-	"github.com/scionproto/scion/go/lib/addr"
 	"io/ioutil"
 	"log"
-	"net/http"
-	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -39,31 +35,60 @@ var (
 	defaultConfig = sc.Default()
 	scionDir      = flag.String("scionDir", "", "Path to SCION root dir")
 
-	episodesFlag     = flag.Int("cEpisodes", defaultConfig.Episodes, "The amount of past episodes to save")
-	wDegreeFlag      = flag.Float64("cWDegree", defaultConfig.WeightDegree, "The weight for the degree")
-	wCapacityFlag    = flag.Float64("cWCapacity", defaultConfig.WeightCapacity, "The weight for the capacity")
-	wSuccessFlag     = flag.Float64("cWSuccess", defaultConfig.WeightSuccess, "The weight for the success")
-	wActivityFlag    = flag.Float64("cWActivity", defaultConfig.WeightActivity, "The weight for the activity")
-	speedCamDiffFlag = flag.Int("cSpeedCamDiff", defaultConfig.SpeedCamDiff, "Additional or fewer speed cams per episode")
-	verboseFlag      = flag.Bool("verbose", defaultConfig.Verbose, "Additional output")
-	resultDirFlag    = flag.String("resultDir", defaultConfig.ResultDir, "Write inspection results to that dir")
-	scaleTypeFlag    = flag.String("scaleType", defaultConfig.ScaleType, "How many SpeedCams should be selected? Supported: const, log and linear")
-	scaleParamFlag   = flag.Float64("scaleParam", defaultConfig.ScaleParam, "The parameter for the scale func. Base for log, factor for linear and the const for const")
+	episodesFlag          = flag.Int("cEpisodes", defaultConfig.Episodes, "The amount of past episodes to save")
+	wDegreeFlag           = flag.Float64("cWDegree", defaultConfig.WeightDegree, "The weight for the degree")
+	wCapacityFlag         = flag.Float64("cWCapacity", defaultConfig.WeightCapacity, "The weight for the capacity")
+	wSuccessFlag          = flag.Float64("cWSuccess", defaultConfig.WeightSuccess, "The weight for the success")
+	wActivityFlag         = flag.Float64("cWActivity", defaultConfig.WeightActivity, "The weight for the activity")
+	wBetweennessFlag      = flag.Float64("cWBetweenness", defaultConfig.WeightBetweenness, "The weight for the betweenness centrality")
+	wPathCentralityFlag   = flag.Float64("cWPathCentrality", defaultConfig.WeightPathCentrality, "The weight for the share of paths in path requests")
+	speedCamDiffFlag      = flag.Int("cSpeedCamDiff", defaultConfig.SpeedCamDiff, "Additional or fewer speed cams per episode")
+	verboseFlag           = flag.Bool("verbose", defaultConfig.Verbose, "Additional output")
+	resultDirFlag         = flag.String("resultDir", defaultConfig.ResultDir, "Write inspection results to that dir")
+	scaleTypeFlag         = flag.String("scaleType", defaultConfig.ScaleType, "How many SpeedCams should be selected? Supported: const, log and linear")
+	scaleParamFlag        = flag.Float64("scaleParam", defaultConfig.ScaleParam, "The parameter for the scale func. Base for log, factor for linear and the const for const")
+	selectionStratFlag    = flag.String("selectionStrategy", defaultConfig.SelectionStrategy, "How the SpeedCams are selected. Supported: probabilistic, topk, weighted, roundrobin and coverage")
+	selectionCoverageFlag = flag.Uint("selectionCoverage", defaultConfig.SelectionCoverage, "Episodes within every AS is selected at least once by the roundrobin strategy")
+	scoreNormFlag         = flag.String("scoreNormalization", defaultConfig.ScoreNormalization, "How the features of the candidate score are normalized before weighting. Supported: minmax, zscore, rank and none")
+	seedFlag              = flag.Int64("seed", defaultConfig.Seed, "Seed of the random selection and wait times. Zero for a seed chosen on start")
 
 	intervalStratFlag = flag.String("intervalStrat", defaultConfig.IntervalStrategy, "Strategy for waiting. Supported: fixed, random and experience")
 	intervalMinFlag   = flag.Uint("intervalMin", defaultConfig.IntervalWaitMin, "Seconds to wait at minimum till next inspection.")
 	intervalMaxFlag   = flag.Uint("intervalMax", defaultConfig.IntervalWaitMax, "Seconds to wait at maximum till next inspection.")
 
-	// mock variables - the external server should handle them in a real application
-	brInfos      []sc.PrometheusClientInfo
-	pathRequests = make(map[string]bool)
+	detectionStratFlag       = flag.String("detectionStrat", defaultConfig.DetectionStrategy, "Rule to detect a congestion. Supported: utilization, overflow, spike and any")
+	detectionUtilizationFlag = flag.Float64("detectionUtilization", defaultConfig.DetectionUtilization, "Share of the capacity a link must use to be detected as congested")
+	detectionOverflowFlag    = flag.Uint64("detectionOverflow", defaultConfig.DetectionOverflow, "Dropped input packets per second a link must exceed to be detected as congested")
+	detectionSpikeFactorFlag = flag.Float64("detectionSpikeFactor", defaultConfig.DetectionSpikeFactor, "Factor the bandwidth must exceed the average of previous episodes to be detected as congested")
+
+	capacityFileFlag = flag.String("capacityFile", defaultConfig.CapacityFile, "JSON file with link capacities")
+	topologyDirFlag  = flag.String("topologyDir", defaultConfig.TopologyDir, "Dir with SCION topology.json files to load link capacities from, e.g. SCION's gen dir")
+	bootstrapFlag    = flag.Bool("topologyBootstrap", defaultConfig.TopologyBootstrap, "Bootstrap the graph with the ASes and links of the topology dir")
+
+	measureStratFlag     = flag.String("measureStrat", defaultConfig.MeasurementStrategy, "How long a SpeedCam measures. Supported: fixed and adaptive")
+	measureMinFlag       = flag.Uint("measureMin", defaultConfig.MeasurementDuration, "Seconds a SpeedCam measures. Minimum for adaptive measurements.")
+	measureMaxFlag       = flag.Uint("measureMax", defaultConfig.MeasurementDurationMax, "Seconds a SpeedCam measures at maximum for adaptive measurements.")
+	measureVariationFlag = flag.Float64("measureVariation", defaultConfig.MeasurementVariation, "Coefficient of variation of the rates above which an adaptive measurement continues")
+	pollIntervalFlag     = flag.Uint("pollInterval", defaultConfig.PollInterval, "Seconds between two polls of a border router")
+	pollConcurrencyFlag  = flag.Int("pollConcurrency", defaultConfig.PollConcurrency, "Maximum of concurrent border router polls")
+	pollHostConcFlag     = flag.Int("pollHostConcurrency", defaultConfig.PollHostConcurrency, "Maximum of concurrent polls per border router host")
+	pollJitterFlag       = flag.Uint("pollJitter", defaultConfig.PollJitter, "Maximum random delay of the first poll in milliseconds")
+
+	graphTtlFlag = flag.Uint("graphTtl", defaultConfig.GraphTTL, "Minutes an AS or link stays in the graph without being seen. Zero for infinity")
+
+	stateFileFlag     = flag.String("stateFile", defaultConfig.StateFile, "Checkpoint the graph and episode history to this file and restore it on startup")
+	stateIntervalFlag = flag.Uint("stateInterval", defaultConfig.StateInterval, "Seconds between two checkpoints to the state file")
+
+	fetchPsIntervalFlag = flag.Uint("fetchPsInterval", defaultConfig.FetchPathRequestInterval, "Seconds between two fetches of the path requests")
+	fetchBrIntervalFlag = flag.Uint("fetchBrInterval", defaultConfig.FetchBrInfoInterval, "Seconds between two fetches of the border router information")
+	fetchBackoffFlag    = flag.Uint("fetchBackoff", defaultConfig.FetchBackoff, "Seconds to wait before retrying a failed fetch, doubled with every further failure")
+	fetchBackoffMaxFlag = flag.Uint("fetchBackoffMax", defaultConfig.FetchBackoffMax, "Maximum seconds to wait before retrying a failed fetch")
+
+	ingestAddrFlag = flag.String("ingestAddr", defaultConfig.IngestAddress, "Listen on this address for pushed path requests and border router information, e.g. ':8090'")
 )
 
 func main() {
 
-	ts := httptest.NewServer(http.HandlerFunc(handler))
-	defer ts.Close()
-
 	flag.Parse()
 
 	if len(*scionDir) == 0 {
@@ -73,13 +98,51 @@ func main() {
 	}
 
 	config := getConfig()
+	// The generated topology of the local network knows the capacities of the links
+	if len(config.TopologyDir) == 0 {
+		config.TopologyDir = *scionDir + "/gen"
+	}
+	// The path requests are pushed to the inspector
+	if len(config.IngestAddress) == 0 {
+		config.IngestAddress = "localhost:8090"
+	}
 	sc.MyLogger.Debugf("Config: %v\n", config)
+	if err := config.Validate(); err != nil {
+		sc.MyLogger.Criticalf("invalid config, err: %v", err)
+		return
+	}
 
-	// parse path requests every minute and send it to mock local HTTP server
+	// Initiate the speed cam algorithm
+	inspector, err := sc.CreateFromState(config)
+	if err != nil {
+		sc.MyLogger.Criticalf("error restoring state, err: %v", err)
+		return
+	}
+	err = inspector.LoadCapacities()
+	if err != nil {
+		sc.MyLogger.Errorf("error loading link capacities, err: %v", err)
+	}
+	err = inspector.LoadTopology()
+	if err != nil {
+		sc.MyLogger.Errorf("error loading topology, err: %v", err)
+	}
+
+	// Stop on SIGINT or SIGTERM
+	ctx, cancel := sc.SignalContext()
+	defer cancel()
+
+	//Start speed cam algorithm without fetchers, the data is pushed
+	go inspector.Start(ctx, nil, sc.PrometheusClientFetcher{})
+
+	// parse path requests every minute and push them to the inspector
 	go func() {
 		logDir := *scionDir + "/logs"
+		ingestUrl := "http://" + config.IngestAddress
+		if strings.HasPrefix(config.IngestAddress, ":") {
+			ingestUrl = "http://localhost" + config.IngestAddress
+		}
 		for {
-			pathServerFetching(logDir, ts.URL)
+			pathServerFetching(logDir, ingestUrl)
 			time.Sleep(1 * time.Minute)
 		}
 	}()
@@ -88,106 +151,78 @@ func main() {
 	go func() {
 		genDir := *scionDir + "/gen"
 		for {
-			brInfos = parseBrInformation(genDir)
+			inspector.AddBrInfo(parseBrInformation(genDir))
 			time.Sleep(1 * time.Minute)
 		}
 	}()
 
-	sc.MyLogger.Debug("Wait 2 seconds to populate the data...")
-	time.Sleep(2 * time.Second)
-	// Initiate the speed cam algorithm
-	inspector := sc.CreateEmptyGraph(config)
-	requestRestFetcher := sc.PathRequestRestFetcher{FetchUrl: ts.URL + "/pathServerRequests"}
-	borderRouterInfoFetcher := sc.PrometheusClientFetcher{FetcherResource: ts.URL + "/prometheusClient"}
-
-	//Start speed cam algorithm
-	go inspector.Start(requestRestFetcher, borderRouterInfoFetcher)
-
-	sc.MyLogger.Debug("Wait 2 seconds before starting the inspection...")
-	time.Sleep(2 * time.Second)
+	sc.MyLogger.Debug("Wait 4 seconds to populate the data before starting the inspection...")
+	time.Sleep(4 * time.Second)
 
 	sc.MyLogger.Debug("Starting inspection loop...")
-	for {
-		inspector.StartInspection()
-		time.Sleep(10 * time.Second)
-	}
-	sc.MyLogger.Debug("Finished loop!")
-}
-
-func getConfig() *sc.SpeedCamConfig {
-	return &sc.SpeedCamConfig{
-		Episodes:         *episodesFlag,
-		WeightDegree:     *wDegreeFlag,
-		WeightCapacity:   *wCapacityFlag,
-		WeightSuccess:    *wSuccessFlag,
-		WeightActivity:   *wActivityFlag,
-		SpeedCamDiff:     *speedCamDiffFlag,
-		Verbose:          *verboseFlag,
-		ResultDir:        *resultDirFlag,
-		ScaleType:        *scaleTypeFlag,
-		ScaleParam:       *scaleParamFlag,
-		IntervalStrategy: *intervalStratFlag,
-		IntervalWaitMin:  *intervalMinFlag,
-		IntervalWaitMax:  *intervalMaxFlag}
-}
-
-// Mock a simple HTTP server to serving the data
-func handler(w http.ResponseWriter, r *http.Request) {
-
-	method := r.Method
-	path := r.URL.Path
-	if path == "/pathServerRequests" {
-		if method == "POST" {
-			handlePostPathRequests(r)
-		} else if method == "GET" {
-			handleGetPathRequests(w)
-		}
-	} else if path == "/prometheusClient" {
-		if method == "GET" {
-			handleGetPrometheusClient(w)
-		} else {
-			sc.MyLogger.Criticalf("POST /prometheusClient unsupported")
+	for ctx.Err() == nil {
+		inspector.StartInspection(ctx)
+		select {
+		case <-ctx.Done():
+		case <-time.After(10 * time.Second):
 		}
 	}
-}
-
-func handleGetPathRequests(w http.ResponseWriter) {
-
-	result := make([]string, 0)
-
-	for k := range pathRequests {
-		result = append(result, k)
-	}
+	sc.MyLogger.Debug("Finished loop!")
 
-	jsonString, err := json.Marshal(result)
+	err = inspector.Checkpoint()
 	if err != nil {
-		sc.MyLogger.Criticalf("error marshalling path requests, err: %v", err)
-		return
+		sc.MyLogger.Errorf("error writing checkpoint, err: %v", err)
 	}
-	w.Write(jsonString)
 }
 
-func handlePostPathRequests(r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
-	var list []string
-	decoder.Decode(&list)
-
-	for _, v := range list {
-		pathRequests[v] = true
-	}
-}
-
-func handleGetPrometheusClient(w http.ResponseWriter) {
-	jsonString, err := json.Marshal(brInfos)
-	if err != nil {
-		sc.MyLogger.Criticalf("error marshalling border router information, err: %v", err)
-		return
-	}
-	w.Write(jsonString)
+func getConfig() *sc.SpeedCamConfig {
+	return &sc.SpeedCamConfig{
+		Episodes:                 *episodesFlag,
+		WeightDegree:             *wDegreeFlag,
+		WeightCapacity:           *wCapacityFlag,
+		WeightSuccess:            *wSuccessFlag,
+		WeightActivity:           *wActivityFlag,
+		WeightBetweenness:        *wBetweennessFlag,
+		WeightPathCentrality:     *wPathCentralityFlag,
+		SpeedCamDiff:             *speedCamDiffFlag,
+		Verbose:                  *verboseFlag,
+		ResultDir:                *resultDirFlag,
+		ScaleType:                *scaleTypeFlag,
+		ScaleParam:               *scaleParamFlag,
+		SelectionStrategy:        *selectionStratFlag,
+		SelectionCoverage:        *selectionCoverageFlag,
+		ScoreNormalization:       *scoreNormFlag,
+		Seed:                     *seedFlag,
+		IntervalStrategy:         *intervalStratFlag,
+		IntervalWaitMin:          *intervalMinFlag,
+		IntervalWaitMax:          *intervalMaxFlag,
+		DetectionStrategy:        *detectionStratFlag,
+		DetectionUtilization:     *detectionUtilizationFlag,
+		DetectionOverflow:        *detectionOverflowFlag,
+		DetectionSpikeFactor:     *detectionSpikeFactorFlag,
+		CapacityFile:             *capacityFileFlag,
+		TopologyDir:              *topologyDirFlag,
+		TopologyBootstrap:        *bootstrapFlag,
+		MeasurementStrategy:      *measureStratFlag,
+		MeasurementDuration:      *measureMinFlag,
+		MeasurementDurationMax:   *measureMaxFlag,
+		MeasurementVariation:     *measureVariationFlag,
+		PollInterval:             *pollIntervalFlag,
+		PollConcurrency:          *pollConcurrencyFlag,
+		PollHostConcurrency:      *pollHostConcFlag,
+		PollJitter:               *pollJitterFlag,
+		GraphTTL:                 *graphTtlFlag,
+		StateFile:                *stateFileFlag,
+		StateInterval:            *stateIntervalFlag,
+		FetchPathRequestInterval: *fetchPsIntervalFlag,
+		FetchBrInfoInterval:      *fetchBrIntervalFlag,
+		FetchBackoff:             *fetchBackoffFlag,
+		FetchBackoffMax:          *fetchBackoffMaxFlag,
+		IngestAddress:            *ingestAddrFlag}
 }
 
 func pathServerFetching(logDir string, url string) {
-	cmd := exec.Command("go", "run", "ps_request_parser/ps_request_parser.go", "-logs="+logDir, "-target="+url+"/pathServerRequests")
+	cmd := exec.Command("go", "run", "ps_request_parser/ps_request_parser.go", "-logs="+logDir, "-target="+url+sc.IngestPathRequests)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	err := cmd.Run()
@@ -301,34 +336,26 @@ func extractCommandInfo(line string, command string, config string) string {
 
 func parseBrTopologyFile(topologyFile string, info *sc.PrometheusClientInfo) {
 
-	readBytes, err := ioutil.ReadFile(topologyFile)
-
+	interfaces, err := sc.LoadTopologyFiles(topologyFile)
 	if err != nil {
 		sc.MyLogger.Criticalf("error reading topology file '%v', err: %v\n", topologyFile, err)
 		return
 	}
 
-	root := make(map[string]interface{})
-	err = json.Unmarshal(readBytes, &root)
-
-	if err != nil {
-		sc.MyLogger.Criticalf("error reading topology file '%v', err: %v\n", topologyFile, err)
-		return
+	// The interfaces of this border router
+	var brInterfaces []sc.TopologyInterface
+	for _, v := range interfaces {
+		if v.BrId == info.BrId {
+			brInterfaces = append(brInterfaces, v)
+		}
 	}
-	sourceIsdAs, _ := addr.IAFromString(root["ISD_AS"].(string))
-	info.SourceIsdAs = sourceIsdAs
-	// Go down the hierarchy
-	borderRouters := root["BorderRouters"].(map[string]interface{})
-	borderRouterInfoObject := borderRouters[info.BrId].(map[string]interface{})
-	interfacesObject := borderRouterInfoObject["Interfaces"].(map[string]interface{})
-	if len(interfacesObject) > 1 {
+	if len(brInterfaces) > 1 {
 		sc.MyLogger.Criticalf("error parsing topology file '%v', too many interfaces!", topologyFile)
 		return
 	}
-	for _, v := range interfacesObject {
-		value := v.(map[string]interface{})
-		targetIsdAs, _ := addr.IAFromString(value["ISD_AS"].(string))
-		info.TargetIsdAs = targetIsdAs
-		break
+	for _, v := range brInterfaces {
+		info.SourceIsdAs = v.Source
+		info.TargetIsdAs = v.Target
+		info.IfId = v.IfId
 	}
 }
@@ -20,7 +20,6 @@ import (
 	"flag"
 	"fmt"
 	"github.com/Meldanor/SCIONLab_SpeedCam/speed_cam"
-	"github.com/c2h5oh/datasize"
 	"github.com/scionproto/scion/go/lib/addr"
 	"io/ioutil"
 	"log"
@@ -183,8 +182,7 @@ func createNodeData(result speed_cam.InspectionResult) []NodeData {
 	for k, v := range result.Graph {
 		nodeData := NodeData{Id: k.String(), Degree: v.Degree, CandidateScore: uint(v.CandidateScore)}
 		nodeIsdAs, _ := addr.IAFromString(nodeData.Id)
-		n := 0
-		bandwidth := datasize.B
+		rates := linkRates{}
 		for _, result := range result.SpeedCamResults {
 			for _, v := range result {
 				for _, r := range v {
@@ -192,17 +190,15 @@ func createNodeData(result speed_cam.InspectionResult) []NodeData {
 						nodeData.WasSpeedCam = true
 					}
 					if r.Source == nodeIsdAs || r.Neighbor == nodeIsdAs {
-						bandwidth += r.BandwidthIn + r.BandwidthOut
-						n++
+						rates.add(r)
 					}
 				}
 			}
 		}
-		if n == 0 {
-			nodeData.AvgBytes = 0
-		} else {
-			nodeData.AvgBytes = float64(bandwidth) / float64(n)
-		}
+		nodeData.AvgBytes = rates.average(rates.bytes)
+		nodeData.AvgPackets = rates.average(rates.packets)
+		nodeData.AvgDropped = rates.average(rates.dropped)
+		nodeData.AvgErrors = rates.average(rates.errors)
 
 		nodeDataSlice = append(nodeDataSlice, nodeData)
 	}
@@ -232,7 +228,20 @@ func createLinkData(result speed_cam.InspectionResult) []LinkData {
 	for k, v := range reducedGraph {
 		for _, n := range v {
 			linkData := LinkData{Source: k, Target: n}
-			linkData.AvgBytes = averageLinkBandwidth(k, n, result) + averageLinkBandwidth(n, k, result)
+			linkData.addHistory(result)
+			// The traffic of both directions adds up, so the averages of each direction are summed
+			forward, backward := linkRates{}, linkRates{}
+			forward.addLink(k, n, result)
+			backward.addLink(n, k, result)
+			linkData.AvgBytes = forward.average(forward.bytes) + backward.average(backward.bytes)
+			linkData.AvgPackets = forward.average(forward.packets) + backward.average(backward.packets)
+			linkData.AvgDropped = forward.average(forward.dropped) + backward.average(backward.dropped)
+			linkData.AvgErrors = forward.average(forward.errors) + backward.average(backward.errors)
+			packetSizes := forward.packetSizes
+			packetSizes.Add(backward.packetSizes)
+			linkData.AvgPacketSize = packetSizes.Mean()
+			linkData.PacketSizeLabels = packetSizes.Labels()
+			linkData.PacketSizeShares = packetSizes.Shares()
 			linksSlice = append(linksSlice, linkData)
 		}
 	}
@@ -240,39 +249,68 @@ func createLinkData(result speed_cam.InspectionResult) []LinkData {
 	return linksSlice
 }
 
-func averageLinkBandwidth(source string, target string, result speed_cam.InspectionResult) float64 {
-	n := 0
-	bandwidthTotal := datasize.B
-
-	sourceIsdAs, _ := addr.IAFromString(source)
-	targetIsdAs, _ := addr.IAFromString(target)
-	for _, v := range result.SpeedCamResults {
-
-		// check source->target way
-		results, exists := v[sourceIsdAs]
-		// Are the results from target to source -> count them
-		if exists && results[0].Neighbor == targetIsdAs {
-			for _, r := range results {
-				bandwidthTotal += r.BandwidthIn + r.BandwidthOut
-				n++
-			}
+// Adds the capacity and bandwidth history of all links between the ASes, parallel links are summed up
+func (linkData *LinkData) addHistory(result speed_cam.InspectionResult) {
+	for _, link := range result.Links {
+		source, target := link.Source.String(), link.Target.String()
+		if !(source == linkData.Source && target == linkData.Target) &&
+			!(source == linkData.Target && target == linkData.Source) {
+			continue
 		}
 
-		// Check target->source way
-		results, exists = v[targetIsdAs]
-		// Are the results from target to source -> count them
-		if exists && results[0].Source == sourceIsdAs {
-			for _, r := range results {
-				bandwidthTotal += r.BandwidthIn + r.BandwidthOut
-				n++
+		linkData.Capacity += float64(link.Capacity)
+		for i, v := range link.Activities {
+			bytes := float64(v.SourceToTarget + v.TargetToSource)
+			if i < len(linkData.History) {
+				linkData.History[i] += bytes
+			} else {
+				linkData.History = append(linkData.History, bytes)
 			}
 		}
 	}
+}
+
+// Sums up the rates of SpeedCam results to average them
+type linkRates struct {
+	n       int
+	bytes   float64
+	packets float64
+	dropped float64
+	errors  float64
+	// Packet sizes of both directions
+	packetSizes speed_cam.PacketSizeHistogram
+}
 
-	if n == 0 {
+func (rates *linkRates) add(r speed_cam.SpeedCamResult) {
+	rates.bytes += float64(r.BandwidthIn + r.BandwidthOut)
+	rates.packets += float64(r.PacketsIn + r.PacketsOut)
+	rates.dropped += float64(r.DroppedIn)
+	rates.errors += float64(r.Errors())
+	rates.packetSizes.Add(r.PacketSizesIn)
+	rates.packetSizes.Add(r.PacketSizesOut)
+	rates.n++
+}
+
+func (rates *linkRates) average(sum float64) float64 {
+	if rates.n == 0 {
 		return 0
 	}
-	return float64(bandwidthTotal) / float64(n)
+	return sum / float64(rates.n)
+}
+
+// Adds the results measured by a SpeedCam on source for its links to target
+func (rates *linkRates) addLink(source string, target string, result speed_cam.InspectionResult) {
+	sourceIsdAs, _ := addr.IAFromString(source)
+	targetIsdAs, _ := addr.IAFromString(target)
+	for _, v := range result.SpeedCamResults {
+		for _, results := range v {
+			if len(results) > 0 && results[0].Source == sourceIsdAs && results[0].Neighbor == targetIsdAs {
+				for _, r := range results {
+					rates.add(r)
+				}
+			}
+		}
+	}
 }
 
 type VisData struct {
@@ -287,11 +325,26 @@ type NodeData struct {
 	Degree         uint
 	CandidateScore uint
 	AvgBytes       float64
+	AvgPackets     float64
+	AvgDropped     float64
+	AvgErrors      float64
 	WasSpeedCam    bool
 }
 
 type LinkData struct {
-	Source   string `json:"source"`
-	Target   string `json:"target"`
-	AvgBytes float64
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	AvgBytes   float64
+	AvgPackets float64
+	AvgDropped float64
+	AvgErrors  float64
+	// Bytes per second, zero if unknown
+	Capacity float64
+	// Bytes per second of the previous episodes in both directions, latest first
+	History []float64
+	// Average bytes per packet
+	AvgPacketSize float64
+	// Share of the packets per packet size bucket
+	PacketSizeLabels []string
+	PacketSizeShares []float64
 }